@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jawher/bateau/query"
+	"github.com/jawher/mow.cli"
+)
+
+// completionCommand configures the completion command, which prints the shell completion scripts
+func completionCommand() func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		shell := cmd.StringArg("SHELL", "", "The shell to complete bateau commands in: bash, zsh or fish")
+
+		cmd.Spec = "SHELL"
+		cmd.Action = func() {
+			script, found := completionScripts[*shell]
+			if !found {
+				fail("Unsupported shell %s, was expecting bash, zsh or fish", *shell)
+			}
+			fmt.Print(script)
+		}
+	}
+}
+
+/*
+completionScripts are the completion scripts of the supported shells, which call bateau __complete with the words
+of the command line following bateau, the last one being the word being completed
+*/
+var completionScripts = map[string]string{
+	"bash": `# bateau completion for bash, e.g. source <(bateau completion bash)
+_bateau() {
+	local IFS=$'\n' word=${COMP_WORDS[COMP_CWORD]} line=${COMP_LINE:0:COMP_POINT} full
+	# bash splits unquoted words on =, complete the whole word and only keep what follows the split
+	full=$word
+	if [[ $word != [\"\']* ]]; then
+		full=${line##*[[:space:]]}
+	fi
+	COMPREPLY=($(bateau __complete -- "${COMP_WORDS[@]:1:COMP_CWORD-1}" "$full" 2>/dev/null))
+	COMPREPLY=("${COMPREPLY[@]#"${full%"$word"}"}")
+	compopt -o nospace
+}
+complete -F _bateau bateau
+`,
+	"zsh": `#compdef bateau
+# bateau completion for zsh, e.g. source <(bateau completion zsh)
+_bateau() {
+	local -a candidates
+	candidates=("${(@f)$(bateau __complete -- "${(@)words[2,CURRENT-1]}" "$PREFIX" 2>/dev/null)}")
+	compadd -Q -S '' -- "${candidates[@]}"
+}
+compdef _bateau bateau
+`,
+	"fish": `# bateau completion for fish, e.g. bateau completion fish | source
+complete -c bateau -f -a '(bateau __complete -- (commandline -opc)[2..-1] (commandline -ct))'
+`,
+}
+
+/*
+completeCommand configures the hidden command called by the completion scripts, which prints the completions of the last
+of the provided words, the words preceding it being the rest of the command line, e.g. the flags selecting the target
+*/
+func completeCommand(cfg config) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		cmd.Hidden = true
+		words := cmd.StringsArg("WORDS", nil, "The command line words up to the completed one")
+
+		cmd.Spec = "[WORDS...]"
+		cmd.Action = func() {
+			if len(*words) == 0 {
+				*words = []string{""}
+			}
+			t, endpoints, contexts := completedTarget((*words)[:len(*words)-1])
+			var eps []dockerEndpoint
+			if resolved, err := resolveEndpoints(cfg.endpoints(endpoints, contexts)); err == nil {
+				eps = resolved
+			}
+			for _, candidate := range complete(*words, t, cfg.Queries, liveValues(t, eps)) {
+				fmt.Println(candidate)
+			}
+		}
+	}
+}
+
+// completedTarget returns the target and the endpoints and contexts selected by the words preceding the completed one
+func completedTarget(words []string) (target, []string, []string) {
+	t := containersTarget
+	var endpoints, contexts []string
+	for i, word := range words {
+		var next string
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+		switch word {
+		case "-i", "--images":
+			t = imagesTarget
+		case "--services":
+			t = servicesTarget
+		case "--tasks":
+			t = tasksTarget
+		case "--nodes":
+			t = nodesTarget
+		case "-e", "--endpoint":
+			endpoints = append(endpoints, next)
+		case "--context":
+			contexts = append(contexts, next)
+		}
+	}
+	return t, endpoints, contexts
+}
+
+// valueFlags are the flags followed by a value which is not completed, e.g. an endpoint
+var valueFlags = map[string]bool{
+	"-e": true, "--endpoint": true, "--context": true, "--var": true, "-f": true, "--file": true,
+}
+
+/*
+complete returns the completions of the last word of a bateau command line: the whole word, e.g. a query, completed by
+the field names, the operators of the field being compared or the values it has, as returned by values.
+*/
+func complete(words []string, t target, queries map[string]string, values func(field string) []string) []string {
+	current := strings.TrimLeft(words[len(words)-1], `'"`)
+	var previous string
+	if len(words) > 1 {
+		previous = words[len(words)-2]
+	}
+	switch {
+	case len(words) > 1 && (words[0] == "json" || words[0] == "fmt"):
+		return nil
+	case len(words) > 1 && words[0] == "completion":
+		return withPrefix("", []string{"bash", "fish", "zsh"}, current)
+	case previous == "--format":
+		return withPrefix("", []string{formatTSV, formatCSV, formatJSON}, current)
+	case previous == "--fields":
+		// complete the last field of the comma separated list
+		base := current[:strings.LastIndex(current, ",")+1]
+		return withPrefix(base, fieldNames(t, values), strings.TrimPrefix(current, base))
+	case valueFlags[previous] || strings.HasPrefix(current, "-"):
+		return nil
+	}
+	return completeQuery(current, t, queries, values)
+}
+
+// completeQuery returns the completions of a partial query
+func completeQuery(input string, t target, queries map[string]string, values func(field string) []string) []string {
+	c := query.Complete(input, t.fields, query.NamedQueries(queries), query.Types(t.types))
+	base := strings.TrimSuffix(input, c.Prefix)
+	var tokens []string
+	switch {
+	case c.Fields && strings.HasPrefix(c.Prefix, "@"):
+		for name := range queries {
+			tokens = append(tokens, "@"+name)
+		}
+	case c.Fields:
+		tokens = fieldNames(t, values)
+		if _, found := query.LookupField(t.fields, c.Prefix); found {
+			// the field is complete, suggest its operators too
+			for _, op := range query.Complete(c.Prefix+" ", t.fields, query.Types(t.types)).Operators {
+				tokens = append(tokens, c.Prefix+op)
+			}
+		}
+	case c.Value && strings.HasPrefix(c.Prefix, "$"):
+		for field := range t.fields {
+			if !strings.HasSuffix(field, "*") {
+				tokens = append(tokens, "$"+field)
+			}
+		}
+	case c.Value:
+		for _, value := range values(c.Field) {
+			tokens = append(tokens, query.Quote(value))
+		}
+	default:
+		// the operators of a field, or the tokens following a complete condition, e.g. "&", "|" or ")"
+		tokens = append(c.Operators, c.Tokens...)
+	}
+	return withPrefix(base, tokens, c.Prefix)
+}
+
+// fieldNames returns the fields of a target, the wildcard fields being completed with the keys returned by values, e.g. label keys
+func fieldNames(t target, values func(field string) []string) []string {
+	var res []string
+	for field := range t.fields {
+		if !strings.HasSuffix(field, "*") {
+			res = append(res, field)
+			continue
+		}
+		prefix := strings.TrimSuffix(field, "*")
+		res = append(res, prefix)
+		for _, key := range values(field) {
+			res = append(res, prefix+key)
+		}
+	}
+	return res
+}
+
+// withPrefix returns the sorted and unique base+token completions, for the tokens starting with prefix
+func withPrefix(base string, tokens []string, prefix string) []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, token := range tokens {
+		if strings.HasPrefix(token, prefix) && !seen[token] {
+			seen[token] = true
+			res = append(res, base+token)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// labeler is implemented by the docker objects having labels, whose keys complete the label.* field
+type labeler interface {
+	labelKeys() []string
+}
+
+/*
+lister is implemented by the docker objects which are inspected for some fields, e.g. containers, and returns the values
+known from their listing, so that completing a value does not inspect every object.
+*/
+type lister interface {
+	listedValue(field string) (interface{}, bool)
+}
+
+/*
+liveValues returns a function listing the values the objects of a target have for a field, or the label keys for label.*,
+the objects being listed once, and only when needed, and never inspected.
+The daemons which cannot be connected to or listed are ignored, the completion using the other values.
+*/
+func liveValues(t target, endpoints []dockerEndpoint) func(field string) []string {
+	var objects []*hostQueryable
+	listed := false
+	return func(field string) []string {
+		if _, found := query.LookupField(t.fields, field); !found {
+			return nil
+		}
+		if !listed {
+			listed = true
+			for _, ep := range endpoints {
+				client, err := newDockerClient(ep)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error while connecting to docker on %s: %v\n", ep.name, err)
+					continue
+				}
+				list, err := t.list(client)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error while listing %s on %s: %v\n", t.name, ep.name, err)
+					continue
+				}
+				for _, obj := range list {
+					objects = append(objects, &hostQueryable{host: ep.name, queryable: obj})
+				}
+			}
+		}
+
+		var res []string
+		for _, obj := range objects {
+			if field == "label.*" {
+				if l, ok := obj.queryable.(labeler); ok {
+					res = append(res, l.labelKeys()...)
+				}
+				continue
+			}
+			if strings.HasSuffix(field, "*") {
+				continue
+			}
+			v, found := obj.listedValue(field)
+			if !found {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				res = append(res, v)
+			case []string:
+				res = append(res, v...)
+			case int, int64:
+				res = append(res, fmt.Sprint(v))
+			}
+		}
+		return res
+	}
+}
+
+// listedValue returns the host, or the value of a field known from the listing of the object
+func (h *hostQueryable) listedValue(field string) (interface{}, bool) {
+	if l, ok := h.queryable.(lister); ok && field != "host" {
+		return l.listedValue(field)
+	}
+	return h.Value(field)
+}
+
+// mapKeys returns the keys of a map, e.g. the label keys of a docker object
+func mapKeys(m map[string]string) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplete(t *testing.T) {
+	values := func(field string) []string {
+		switch field {
+		case "label.*":
+			return []string{"env", "team"}
+		case "label.env":
+			return []string{"prod", "prod env", "staging", "prod"}
+		case "name":
+			return []string{"web", "db"}
+		}
+		return nil
+	}
+	queries := map[string]string{"stale": "created>2w & !running", "web": "name~web"}
+
+	cases := []struct {
+		words    []string
+		expected []string
+	}{
+		{[]string{"runn"}, []string{"running"}},
+		{[]string{"running & lab"}, []string{"running & label.", "running & label.env", "running & label.team"}},
+		{[]string{"'running & label.env="}, []string{"running & label.env=\"prod env\"", "running & label.env=prod", "running & label.env=staging"}},
+		{[]string{"name~w"}, []string{"name~web"}},
+		{[]string{"name"}, []string{"name", "name!=", "name!~", "name=", "name~"}},
+		{[]string{"name "}, []string{"name !=", "name !~", "name =", "name ~"}},
+		{[]string{"running "}, []string{"running &", "running |"}},
+		{[]string{"(running | exit>0 "}, []string{"(running | exit>0 &", "(running | exit>0 )", "(running | exit>0 |"}},
+		{[]string{"exit>$crea"}, []string{"exit>$created"}},
+		{[]string{"@"}, []string{"@stale", "@web"}},
+		{[]string{"running & (paused | res"}, []string{"running & (paused | restarting"}},
+		{[]string{"-i", "ta"}, []string{"tag"}},
+		{[]string{"--format", "c"}, []string{"csv"}},
+		{[]string{"--fields", "id,na"}, []string{"id,name"}},
+		{[]string{"completion", ""}, []string{"bash", "fish", "zsh"}},
+		{[]string{"-e", "unix"}, nil},
+		{[]string{"--"}, nil},
+		{[]string{"json", "na"}, nil},
+	}
+
+	for _, c := range cases {
+		tg, _, _ := completedTarget(c.words[:len(c.words)-1])
+		require.Equal(t, c.expected, complete(c.words, tg, queries, values), "completing %q", c.words)
+	}
+}
+
+func TestLiveValues(t *testing.T) {
+	// the containers are not inspected: the fake daemon only answers their listing
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[
+	{"Id": "con1", "Names": ["/db/web-db", "/web-1"], "Labels": {"env": "prod"}},
+	{"Id": "con2", "Names": ["/db"], "Labels": {"env": "staging", "team": "data"}}
+]`)
+	}))
+	defer server.Close()
+
+	values := liveValues(containersTarget, []dockerEndpoint{
+		{name: "local", host: server.URL},
+		// the daemons which cannot be connected to are skipped
+		{name: "remote", host: "tcp://remote:2376", tls: true, cert: "missing-cert.pem", key: "missing-key.pem"},
+	})
+
+	require.Equal(t, []string{"web-1", "db"}, values("name"))
+	require.Equal(t, []string{"prod", "staging"}, values("label.env"))
+	require.ElementsMatch(t, []string{"env", "env", "team"}, values("label.*"))
+	require.Equal(t, []string{"local", "local"}, values("host"))
+	require.Empty(t, values("cmd"))
+}
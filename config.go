@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+config holds the user defaults, read from ~/.config/bateau/config.toml or ~/.config/bateau/config.yaml
+*/
+type config struct {
+	// Endpoint and Context are the docker daemon to use when neither -e nor --context is provided
+	Endpoint string `toml:"endpoint" yaml:"endpoint"`
+	Context  string `toml:"context" yaml:"context"`
+	// Format is the default output format
+	Format string `toml:"format" yaml:"format"`
+	// Queries are the named queries, which can be referenced with @name in queries
+	Queries map[string]string `toml:"queries" yaml:"queries"`
+}
+
+// configFiles are the configuration file names bateau looks for, in order
+var configFiles = []string{"config.toml", "config.yaml", "config.yml"}
+
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); len(dir) != 0 {
+		return filepath.Join(dir, "bateau")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "bateau")
+}
+
+/*
+loadConfig reads the first configuration file found in the configuration directory.
+A missing configuration file is not an error.
+*/
+func loadConfig(dir string) (config, error) {
+	var res config
+	if len(dir) == 0 {
+		return res, nil
+	}
+	for _, name := range configFiles {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		if err := parseConfig(filepath.Ext(name), data, &res); err != nil {
+			return res, fmt.Errorf("Invalid configuration file %s: %v", path, err)
+		}
+		return res, nil
+	}
+	return res, nil
+}
+
+func parseConfig(ext string, data []byte, res *config) error {
+	switch ext {
+	case ".toml":
+		meta, err := toml.Decode(string(data), res)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) != 0 {
+			return fmt.Errorf("unknown key %s", undecoded[0])
+		}
+	default:
+		if err := yaml.UnmarshalStrict(data, res); err != nil {
+			return err
+		}
+	}
+	if len(res.Endpoint) != 0 && len(res.Context) != 0 {
+		return fmt.Errorf("endpoint and context are mutually exclusive")
+	}
+	return nil
+}
+
+/*
+endpoints returns the docker daemons selected with -e and --context, or the configuration defaults when none is
+provided and neither DOCKER_HOST nor DOCKER_CONTEXT is set, so that the environment takes precedence over the configuration
+*/
+func (c config) endpoints(hosts, contexts []string) ([]string, []string) {
+	if len(hosts) != 0 || len(contexts) != 0 || len(os.Getenv("DOCKER_HOST")) != 0 || len(os.Getenv("DOCKER_CONTEXT")) != 0 {
+		return hosts, contexts
+	}
+	return nonEmpty(c.Endpoint), nonEmpty(c.Context)
+}
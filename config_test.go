@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	expected := config{
+		Endpoint: "ssh://deploy@build-1",
+		Format:   "json",
+		Queries: map[string]string{
+			"stale": "created > 2w & !running & name!=precious",
+		},
+	}
+	cases := map[string]string{
+		"config.toml": `
+endpoint = "ssh://deploy@build-1"
+format = "json"
+
+[queries]
+stale = "created > 2w & !running & name!=precious"
+`,
+		"config.yaml": `
+endpoint: ssh://deploy@build-1
+format: json
+queries:
+  stale: created > 2w & !running & name!=precious
+`,
+	}
+
+	for name, content := range cases {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+
+		cfg, err := loadConfig(dir)
+		require.NoError(t, err, "loading %s", name)
+		require.Equal(t, expected, cfg, "loading %s", name)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	cfg, err := loadConfig(t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, config{}, cfg)
+
+	cases := map[string]string{
+		"config.toml": `formt = "json"`,
+		"config.yaml": "queries: [a, b]",
+		"config.yml":  "endpoint: tcp://build-1:2375\ncontext: build-2",
+	}
+	for name, content := range cases {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+
+		_, err := loadConfig(dir)
+		require.Error(t, err, "loading %s", name)
+		t.Log(err)
+	}
+}
+
+func TestConfigEndpoints(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	cfg := config{Endpoint: "ssh://deploy@build-1"}
+
+	hosts, contexts := cfg.endpoints(nil, nil)
+	require.Equal(t, []string{"ssh://deploy@build-1"}, hosts)
+	require.Nil(t, contexts)
+
+	// the options win over the configuration
+	hosts, contexts = cfg.endpoints(nil, []string{"build-2"})
+	require.Nil(t, hosts)
+	require.Equal(t, []string{"build-2"}, contexts)
+
+	// and so does the environment
+	t.Setenv("DOCKER_CONTEXT", "build-3")
+	hosts, contexts = cfg.endpoints(nil, nil)
+	require.Nil(t, hosts)
+	require.Nil(t, contexts)
+
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("DOCKER_HOST", "tcp://build-4:2375")
+	hosts, contexts = cfg.endpoints(nil, nil)
+	require.Nil(t, hosts)
+	require.Nil(t, contexts)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 
 	"strings"
 
@@ -15,23 +16,46 @@ var (
 		"paused":     {query.IS},
 		"restarting": {query.IS},
 
-		"label.*": {query.IS, query.EQ, query.LIKE},
+		"label.*":       {query.IS, query.EQ, query.LIKE},
+		"image.label.*": {query.IS, query.EQ, query.LIKE},
 
 		"id":         {query.EQ, query.LIKE},
 		"name":       {query.EQ, query.LIKE},
 		"image":      {query.EQ, query.LIKE},
 		"cmd":        {query.EQ, query.LIKE},
 		"entrypoint": {query.EQ, query.LIKE},
+		"network":    {query.EQ, query.LIKE},
 
-		"exit":    {query.EQ, query.GT},
-		"created": {query.EQ, query.GT},
-		"exited":  {query.EQ, query.GT}}
+		"exit":    orderOperators,
+		"created": orderOperators,
+		"exited":  orderOperators}
+
+	conTypes = map[string]query.Type{
+		"running":    query.TypeBool,
+		"paused":     query.TypeBool,
+		"restarting": query.TypeBool,
+
+		"label.*":       query.TypeString,
+		"image.label.*": query.TypeString,
+
+		"id":         query.TypeString,
+		"name":       query.TypeString,
+		"image":      query.TypeString,
+		"cmd":        query.TypeList,
+		"entrypoint": query.TypeList,
+		"network":    query.TypeList,
+
+		"exit":    query.TypeInt,
+		"created": query.TypeDuration,
+		"exited":  query.TypeDuration,
+	}
 )
 
 type DockerContainer struct {
 	client        *docker.Client
 	apiContainer  docker.APIContainers
 	fullContainer *docker.Container
+	image         *docker.Image
 }
 
 func wrapContainer(client *docker.Client, apiContainer docker.APIContainers) *DockerContainer {
@@ -41,46 +65,104 @@ func wrapContainer(client *docker.Client, apiContainer docker.APIContainers) *Do
 	}
 }
 
-var _ query.Queryable = &DockerContainer{}
+var _ query.SliceQueryable = &DockerContainer{}
+
+func listContainers(client *docker.Client) ([]queryable, error) {
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]queryable, len(containers))
+	for i, container := range containers {
+		res[i] = wrapContainer(client, container)
+	}
+	return res, nil
+}
 
 func (c *DockerContainer) Is(field string, operator query.Operator, value string) bool {
+	v, found := c.Value(field)
+	return valueCompare(v, found, operator, value)
+}
+
+func (c *DockerContainer) Elements(field string) []query.Queryable {
+	return elements(c, field)
+}
+
+/*
+Value returns the value of the provided field, or false if the container has no value for it.
+It is the single place where bateau fields are resolved against a container, both for querying and for output.
+*/
+func (c *DockerContainer) Value(field string) (interface{}, bool) {
 	switch {
 	case field == "running":
-		return c.full().State.Running
+		return c.full().State.Running, true
 	case field == "paused":
-		return c.full().State.Paused
+		return c.full().State.Paused, true
 	case field == "restarting":
-		return c.full().State.Restarting
+		return c.full().State.Restarting, true
 	case strings.HasPrefix(field, "label."):
 		label := strings.TrimPrefix(field, "label.")
 		labelValue, found := c.full().Config.Labels[label]
-		if operator == query.IS {
-			return found
-		}
-		return strCompare(labelValue, operator, value)
+		return labelValue, found
+	case strings.HasPrefix(field, "image.label."):
+		label := strings.TrimPrefix(field, "image.label.")
+		labelValue, found := c.fullImage().Config.Labels[label]
+		return labelValue, found
 	case field == "id":
-		return strCompare(c.apiContainer.ID, operator, value)
+		return c.apiContainer.ID, true
 	case field == "name":
-		return strCompare(strings.TrimPrefix(c.full().Name, "/"), operator, value)
+		return strings.TrimPrefix(c.full().Name, "/"), true
 	case field == "image":
-		return strCompare(c.apiContainer.Image, operator, value)
+		return c.apiContainer.Image, true
 	case field == "exit":
 		code := c.full().State.ExitCode
-		return code != -1 && intCompare(code, operator, value)
+		return code, code != -1
 	case field == "cmd":
-		return sliceCompare(c.full().Config.Cmd, operator, value)
+		return c.full().Config.Cmd, true
 	case field == "entrypoint":
-		return sliceCompare(c.full().Config.Entrypoint, operator, value)
+		return c.full().Config.Entrypoint, true
+	case field == "network":
+		var networks []string
+		for name := range c.apiContainer.Networks.Networks {
+			networks = append(networks, name)
+		}
+		sort.Strings(networks)
+		return networks, true
 	case field == "created":
-		return durationCompare(c.full().Created, operator, value)
+		return c.full().Created, true
 	case field == "exited":
 		finishedAt := c.full().State.FinishedAt
-		return !finishedAt.IsZero() && durationCompare(finishedAt, operator, value)
+		return finishedAt, !finishedAt.IsZero()
 	default:
 		panic(fmt.Sprintf("Invalid field %s", field))
 	}
 }
 
+func (c *DockerContainer) labelKeys() []string {
+	return mapKeys(c.apiContainer.Labels)
+}
+
+// listedValue returns the value of the fields known from the container listing, e.g. its name, without inspecting it
+func (c *DockerContainer) listedValue(field string) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(field, "label."):
+		labelValue, found := c.apiContainer.Labels[strings.TrimPrefix(field, "label.")]
+		return labelValue, found
+	case field == "name":
+		// the listed names also include the /linker/alias names of the links to the container
+		for _, name := range c.apiContainer.Names {
+			if name = strings.TrimPrefix(name, "/"); !strings.Contains(name, "/") {
+				return name, true
+			}
+		}
+		return "", false
+	case field == "id", field == "image", field == "network":
+		return c.Value(field)
+	default:
+		return nil, false
+	}
+}
+
 func (c *DockerContainer) full() *docker.Container {
 	if c.fullContainer != nil {
 		return c.fullContainer
@@ -92,3 +174,16 @@ func (c *DockerContainer) full() *docker.Container {
 	c.fullContainer = daRealContainer
 	return c.fullContainer
 }
+
+// fullImage returns the image the container was created from, e.g. to compare the container and image labels
+func (c *DockerContainer) fullImage() *docker.Image {
+	if c.image != nil {
+		return c.image
+	}
+	image, err := c.client.InspectImage(c.full().Image)
+	if err != nil {
+		fail("Error while retreiving the image of container %s: %v", c.apiContainer.ID, err)
+	}
+	c.image = image
+	return c.image
+}
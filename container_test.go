@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeContainers = `[
+	{"Id": "con1", "Image": "org/web:1.2", "NetworkSettings": {"Networks": {"front": {}, "back": {}}}},
+	{"Id": "con2", "Image": "org/web:1.1", "NetworkSettings": {"Networks": {"back": {}}}}
+]`
+	fakeContainer1 = `{
+	"Id": "con1",
+	"Name": "/web-1",
+	"Image": "sha256:img2",
+	"Created": "2020-01-01T00:00:00Z",
+	"Config": {"Labels": {"version": "1.2"}},
+	"State": {"Running": false, "ExitCode": 1, "FinishedAt": "2020-01-01T00:30:00Z"}
+}`
+	fakeContainer2 = `{
+	"Id": "con2",
+	"Name": "/web-2",
+	"Image": "sha256:img1",
+	"Created": "2020-01-01T00:00:00Z",
+	"Config": {"Labels": {"version": "1.2"}},
+	"State": {"Running": false, "ExitCode": 0, "FinishedAt": "2020-01-02T00:00:00Z"}
+}`
+	fakeImage1 = `{"Id": "sha256:img1", "Config": {"Labels": {"version": "1.1"}}}`
+	fakeImage2 = `{"Id": "sha256:img2", "Config": {"Labels": {"version": "1.2"}}}`
+)
+
+func TestContainerQueries(t *testing.T) {
+	client, stop := newFakeDocker(t, map[string]string{
+		"/containers/json":         fakeContainers,
+		"/containers/con1/json":    fakeContainer1,
+		"/containers/con2/json":    fakeContainer2,
+		"/images/sha256:img1/json": fakeImage1,
+		"/images/sha256:img2/json": fakeImage2,
+	})
+	defer stop()
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"network=front", []string{"con1"}},
+		{"count(network)>=2", []string{"con1"}},
+		{"image.label.version=1.1", []string{"con2"}},
+		{"label.version!=$image.label.version", []string{"con2"}},
+		{"label.version=$image.label.version & len(name)=5", []string{"con1"}},
+		{"exited > $created - 1h", []string{"con1"}},
+		{"exited > created - 1h", []string{"con1"}},
+		{"exited < $created - 2 * 6h", []string{"con2"}},
+	}
+
+	for _, cas := range cases {
+		require.Equal(t, cas.expected, matchingIDs(t, containersTarget, client, cas.query), "query '%s'", cas.query)
+	}
+}
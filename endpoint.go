@@ -0,0 +1,248 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/jawher/bateau/query"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+/*
+dockerEndpoint describes how to connect to a docker daemon
+*/
+type dockerEndpoint struct {
+	// name identifies the daemon in the synthetic host field
+	name string
+	host string
+
+	tls        bool
+	skipVerify bool
+	ca         string
+	cert       string
+	key        string
+}
+
+/*
+resolveEndpoints returns the docker daemons to query given the -e and --context options.
+When none is provided, it falls back, like the docker CLI, to the DOCKER_HOST environment variable,
+then to the DOCKER_CONTEXT environment variable, then to the current context of the docker CLI configuration,
+and finally to the local docker socket.
+*/
+func resolveEndpoints(hosts, contexts []string) ([]dockerEndpoint, error) {
+	var res []dockerEndpoint
+	for _, host := range hosts {
+		res = append(res, hostEndpoint(host))
+	}
+	for _, name := range contexts {
+		ep, err := contextEndpoint(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ep)
+	}
+	if len(res) != 0 {
+		return res, nil
+	}
+
+	if host := os.Getenv("DOCKER_HOST"); len(host) != 0 {
+		return []dockerEndpoint{hostEndpoint(host)}, nil
+	}
+
+	name := os.Getenv("DOCKER_CONTEXT")
+	if len(name) == 0 {
+		config, err := readDockerConfig()
+		if err != nil {
+			return nil, err
+		}
+		name = config.CurrentContext
+	}
+	if len(name) != 0 && name != "default" {
+		ep, err := contextEndpoint(name)
+		if err != nil {
+			return nil, err
+		}
+		return []dockerEndpoint{ep}, nil
+	}
+
+	ep := hostEndpoint(defaultDockerHost)
+	ep.name = "default"
+	return []dockerEndpoint{ep}, nil
+}
+
+/*
+hostEndpoint returns the endpoint for a docker socket path or TCP address.
+TLS is configured from the DOCKER_TLS_VERIFY and DOCKER_CERT_PATH environment variables.
+*/
+func hostEndpoint(host string) dockerEndpoint {
+	res := dockerEndpoint{
+		name: hostName(host),
+		host: host,
+	}
+	if len(os.Getenv("DOCKER_TLS_VERIFY")) != 0 {
+		certPath := os.Getenv("DOCKER_CERT_PATH")
+		res.tls = true
+		res.ca = filepath.Join(certPath, "ca.pem")
+		res.cert = filepath.Join(certPath, "cert.pem")
+		res.key = filepath.Join(certPath, "key.pem")
+	}
+	return res
+}
+
+/*
+hostName returns the name of the machine a docker endpoint points to, e.g. build-3 for tcp://build-3:2376,
+or the endpoint itself if it has none, e.g. for unix sockets
+*/
+func hostName(host string) string {
+	u, err := url.Parse(host)
+	if err != nil || len(u.Hostname()) == 0 {
+		return host
+	}
+	return u.Hostname()
+}
+
+// dockerConfig is the subset of the docker CLI configuration file used by bateau
+type dockerConfig struct {
+	CurrentContext string `json:"currentContext"`
+}
+
+// contextMeta is the subset of the docker CLI context metadata used by bateau
+type contextMeta struct {
+	Name      string
+	Endpoints map[string]struct {
+		Host          string
+		SkipTLSVerify bool
+	}
+}
+
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); len(dir) != 0 {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+func readDockerConfig() (dockerConfig, error) {
+	var res dockerConfig
+	data, err := os.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	switch {
+	case os.IsNotExist(err):
+		return res, nil
+	case err != nil:
+		return res, err
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("Invalid docker configuration: %v", err)
+	}
+	return res, nil
+}
+
+/*
+contextEndpoint returns the endpoint of a docker CLI context, read from the contexts store of the docker configuration.
+Contexts are stored in directories named after the SHA256 of their name: the metadata in contexts/meta
+and the TLS material in contexts/tls.
+*/
+func contextEndpoint(name string) (dockerEndpoint, error) {
+	if name == "default" {
+		ep := hostEndpoint(defaultDockerHost)
+		ep.name = name
+		return ep, nil
+	}
+
+	id := contextID(name)
+	store := filepath.Join(dockerConfigDir(), "contexts")
+
+	data, err := os.ReadFile(filepath.Join(store, "meta", id, "meta.json"))
+	if os.IsNotExist(err) {
+		return dockerEndpoint{}, fmt.Errorf("Unknown docker context %s", name)
+	}
+	if err != nil {
+		return dockerEndpoint{}, err
+	}
+	var meta contextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return dockerEndpoint{}, fmt.Errorf("Invalid docker context %s: %v", name, err)
+	}
+	endpoint, found := meta.Endpoints["docker"]
+	if !found || len(endpoint.Host) == 0 {
+		return dockerEndpoint{}, fmt.Errorf("Docker context %s has no docker endpoint", name)
+	}
+
+	res := dockerEndpoint{
+		name:       name,
+		host:       endpoint.Host,
+		skipVerify: endpoint.SkipTLSVerify,
+	}
+	tlsDir := filepath.Join(store, "tls", id, "docker")
+	if _, err := os.Stat(tlsDir); err == nil || endpoint.SkipTLSVerify {
+		res.tls = true
+		res.ca = filepath.Join(tlsDir, "ca.pem")
+		res.cert = filepath.Join(tlsDir, "cert.pem")
+		res.key = filepath.Join(tlsDir, "key.pem")
+	}
+	return res, nil
+}
+
+// contextID returns the name of the directories where the docker CLI stores a context
+func contextID(name string) string {
+	digest := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(digest[:])
+}
+
+/*
+hostQueryable adds the synthetic host field, naming the docker daemon an object comes from, to a queryable
+*/
+type hostQueryable struct {
+	host string
+	queryable
+}
+
+func (h *hostQueryable) Is(field string, operator query.Operator, value string) bool {
+	if field == "host" {
+		return valueCompare(h.host, true, operator, value)
+	}
+	return h.queryable.Is(field, operator, value)
+}
+
+func (h *hostQueryable) Value(field string) (interface{}, bool) {
+	if field == "host" {
+		return h.host, true
+	}
+	return h.queryable.Value(field)
+}
+
+func (h *hostQueryable) Elements(field string) []query.Queryable {
+	return elements(h, field)
+}
+
+// withHost adds the synthetic host field to a target fields
+func withHost(fields map[string][]query.Operator) map[string][]query.Operator {
+	res := map[string][]query.Operator{
+		"host": {query.EQ, query.LIKE},
+	}
+	for k, v := range fields {
+		res[k] = v
+	}
+	return res
+}
+
+// withHostType adds the type of the synthetic host field to a target field types
+func withHostType(types map[string]query.Type) map[string]query.Type {
+	res := map[string]query.Type{
+		"host": query.TypeString,
+	}
+	for k, v := range types {
+		res[k] = v
+	}
+	return res
+}
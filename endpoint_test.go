@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jawher/bateau/query"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDockerContext(t *testing.T, configDir, name, meta string, withTLS bool) {
+	id := contextID(name)
+
+	metaDir := filepath.Join(configDir, "contexts", "meta", id)
+	require.NoError(t, os.MkdirAll(metaDir, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0600))
+
+	if withTLS {
+		require.NoError(t, os.MkdirAll(filepath.Join(configDir, "contexts", "tls", id, "docker"), 0700))
+	}
+}
+
+func TestResolveEndpoints(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+
+	writeDockerContext(t, configDir, "build-3",
+		`{"Name":"build-3","Endpoints":{"docker":{"Host":"tcp://10.0.0.3:2376","SkipTLSVerify":false}}}`, true)
+	writeDockerContext(t, configDir, "staging",
+		`{"Name":"staging","Endpoints":{"docker":{"Host":"tcp://staging:2375"}}}`, false)
+
+	tlsDir := filepath.Join(configDir, "contexts", "tls", contextID("build-3"), "docker")
+
+	// nothing configured: the local socket
+	eps, err := resolveEndpoints(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []dockerEndpoint{{name: "default", host: defaultDockerHost}}, eps)
+
+	// the docker CLI current context
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"staging"}`), 0600))
+	eps, err = resolveEndpoints(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []dockerEndpoint{{name: "staging", host: "tcp://staging:2375"}}, eps)
+
+	// DOCKER_CONTEXT wins over the current context
+	t.Setenv("DOCKER_CONTEXT", "build-3")
+	eps, err = resolveEndpoints(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []dockerEndpoint{{
+		name: "build-3",
+		host: "tcp://10.0.0.3:2376",
+		tls:  true,
+		ca:   filepath.Join(tlsDir, "ca.pem"),
+		cert: filepath.Join(tlsDir, "cert.pem"),
+		key:  filepath.Join(tlsDir, "key.pem"),
+	}}, eps)
+
+	// DOCKER_HOST wins over DOCKER_CONTEXT
+	t.Setenv("DOCKER_HOST", "tcp://build-1:2375")
+	eps, err = resolveEndpoints(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []dockerEndpoint{{name: "build-1", host: "tcp://build-1:2375"}}, eps)
+
+	// and explicit options win over everything
+	eps, err = resolveEndpoints([]string{"unix:///tmp/docker.sock", "tcp://build-2:2375"}, []string{"staging", "default"})
+	require.NoError(t, err)
+	require.Equal(t, []dockerEndpoint{
+		{name: "unix:///tmp/docker.sock", host: "unix:///tmp/docker.sock"},
+		{name: "build-2", host: "tcp://build-2:2375"},
+		{name: "staging", host: "tcp://staging:2375"},
+		{name: "default", host: defaultDockerHost},
+	}, eps)
+
+	_, err = resolveEndpoints(nil, []string{"nope"})
+	require.Error(t, err)
+}
+
+func TestQueryEndpoints(t *testing.T) {
+	clientA, stopA := newFakeDocker(t, map[string]string{"/nodes": fakeNodes})
+	defer stopA()
+	clientB, stopB := newFakeDocker(t, map[string]string{"/nodes": fakeNodes})
+	defer stopB()
+
+	matcher, err := query.Parse("host=b & role=worker", nodesTarget.fields)
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	out, err := newOutput(&buffer, "host,id,hostname", formatTSV, false, nodesTarget.fields)
+	require.NoError(t, err)
+
+	require.NoError(t, queryEndpoint(nodesTarget, matcher, clientA, "a", out))
+	require.NoError(t, queryEndpoint(nodesTarget, matcher, clientB, "b", out))
+	require.Equal(t, "b\tnode2\tbuild-2\n", buffer.String())
+
+	clientC, stopC := newFakeDocker(t, map[string]string{})
+	defer stopC()
+	require.Error(t, queryEndpoint(nodesTarget, matcher, clientC, "c", out))
+}
+
+func TestQueryEndpointsErrors(t *testing.T) {
+	server := newFakeDaemon(map[string]string{"/nodes": fakeNodes})
+	defer server.Close()
+
+	matcher, err := query.Parse("role=worker", nodesTarget.fields)
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	out, err := newOutput(&buffer, "host,id", formatTSV, false, nodesTarget.fields)
+	require.NoError(t, err)
+
+	// the daemons which cannot be connected to are reported without preventing the others from being queried
+	ok := queryEndpoints(nodesTarget, matcher, []dockerEndpoint{
+		{name: "remote", host: "tcp://remote:2376", tls: true, cert: "missing-cert.pem", key: "missing-key.pem"},
+		{name: "local", host: server.URL},
+	}, out)
+	require.False(t, ok)
+	require.NoError(t, out.flush())
+	require.Equal(t, "local\tnode2\n", buffer.String())
+}
+
+func TestTargetTypes(t *testing.T) {
+	for _, tg := range []target{containersTarget, imagesTarget, servicesTarget, tasksTarget, nodesTarget} {
+		require.Len(t, tg.types, len(tg.fields), "%s fields and types", tg.name)
+		for field := range tg.fields {
+			require.Contains(t, tg.types, field, "type of the %s field %s", tg.name, field)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jawher/bateau/query"
+	"github.com/jawher/mow.cli"
+)
+
+// fmtCommand configures the fmt command, which prints queries in their canonical form, expanding the named queries of cfg
+func fmtCommand(cfg func() config) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		write := cmd.BoolOpt("w write", false, "Write the formatted query back to the file provided with -f instead of printing it, unless it has comments or named query references")
+		queryFile := cmd.StringOpt("f file", "", "Read the query from a file, or from the standard input for -")
+		queryStr := cmd.StringArg("QUERY", "", "The query to format")
+
+		cmd.Spec = "[-w] (-f | QUERY)"
+		cmd.Action = func() {
+			if *write && (len(*queryFile) == 0 || *queryFile == "-") {
+				fail("-w requires a query file")
+			}
+			queryStr := queryOrFail(*queryFile, *queryStr)
+			if *write && query.Annotated(queryStr) {
+				fail("%s has comments or named query references, which the formatted query would lose, format it without -w instead", *queryFile)
+			}
+			formatted, err := formatQuery(queryStr, cfg().Queries)
+			if err != nil {
+				fail("Invalid query: %v", err)
+			}
+			if !*write {
+				fmt.Println(formatted)
+				return
+			}
+			if err := os.WriteFile(*queryFile, []byte(formatted+"\n"), 0644); err != nil {
+				fail("Error while writing the query: %v", err)
+			}
+		}
+	}
+}
+
+/*
+formatQuery returns the canonical form of a query.
+Any field is accepted, so that queries on every target can be formatted, and the $name references are kept as is,
+while the named queries are expanded and the comments dropped, which is why fmt -w refuses to rewrite such queries.
+*/
+func formatQuery(queryStr string, queries map[string]string) (string, error) {
+	ast, err := query.Parse(queryStr, query.MapFields, query.NamedQueries(queries))
+	if err != nil {
+		return "", err
+	}
+	return query.Format(ast), nil
+}
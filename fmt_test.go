@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatQuery(t *testing.T) {
+	formatted, err := formatQuery("@stale and ( name ~ web or label.env = \"prod env\" ) # old web containers\n& exited > $MAX_AGE", map[string]string{
+		"stale": "created>2w & !running",
+	})
+	require.NoError(t, err)
+	require.Equal(t, `created>2w & !running & (name~web | label.env="prod env") & exited>$MAX_AGE`, formatted)
+
+	_, err = formatQuery("name=", nil)
+	require.Error(t, err)
+}
@@ -22,8 +22,24 @@ var (
 
 		"label.*": {query.IS, query.EQ, query.LIKE},
 
-		"size":    {query.EQ, query.GT},
-		"created": {query.EQ, query.GT},
+		"size":    orderOperators,
+		"created": orderOperators,
+	}
+
+	imgTypes = map[string]query.Type{
+		"id":             query.TypeString,
+		"tag":            query.TypeList,
+		"cmd":            query.TypeList,
+		"entrypoint":     query.TypeList,
+		"comment":        query.TypeString,
+		"author":         query.TypeString,
+		"arch":           query.TypeString,
+		"docker_version": query.TypeString,
+
+		"label.*": query.TypeString,
+
+		"size":    query.TypeSize,
+		"created": query.TypeDuration,
 	}
 )
 
@@ -40,42 +56,81 @@ func wrapImage(client *docker.Client, apiImage docker.APIImages) *DockerImage {
 	}
 }
 
-var _ query.Queryable = &DockerImage{}
+var _ query.SliceQueryable = &DockerImage{}
+
+func listImages(client *docker.Client) ([]queryable, error) {
+	images, err := client.ListImages(docker.ListImagesOptions{All: false})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]queryable, len(images))
+	for i, image := range images {
+		res[i] = wrapImage(client, image)
+	}
+	return res, nil
+}
 
 func (c *DockerImage) Is(field string, operator query.Operator, value string) bool {
+	v, found := c.Value(field)
+	return valueCompare(v, found, operator, value)
+}
+
+func (c *DockerImage) Elements(field string) []query.Queryable {
+	return elements(c, field)
+}
+
+/*
+Value returns the value of the provided field, or false if the image has no value for it.
+It is the single place where bateau fields are resolved against an image, both for querying and for output.
+*/
+func (c *DockerImage) Value(field string) (interface{}, bool) {
 	switch {
 	case strings.HasPrefix(field, "label."):
 		label := strings.TrimPrefix(field, "label.")
 		labelValue, found := c.full().Config.Labels[label]
-		if operator == query.IS {
-			return found
-		}
-		return strCompare(labelValue, operator, value)
+		return labelValue, found
 	case field == "id":
-		return strCompare(c.apiImage.ID, operator, value)
+		return c.apiImage.ID, true
 	case field == "tag":
-		return sliceCompare(c.apiImage.RepoTags, operator, value)
+		return c.apiImage.RepoTags, true
 	case field == "docker_version":
-		return strCompare(c.full().DockerVersion, operator, value)
+		return c.full().DockerVersion, true
 	case field == "comment":
-		return strCompare(c.full().Comment, operator, value)
+		return c.full().Comment, true
 	case field == "author":
-		return strCompare(c.full().Author, operator, value)
+		return c.full().Author, true
 	case field == "arch":
-		return strCompare(c.full().Architecture, operator, value)
+		return c.full().Architecture, true
 	case field == "cmd":
-		return sliceCompare(c.full().Config.Cmd, operator, value)
+		return c.full().Config.Cmd, true
 	case field == "entrypoint":
-		return sliceCompare(c.full().Config.Entrypoint, operator, value)
+		return c.full().Config.Entrypoint, true
 	case field == "size":
-		return sizeCompare(c.apiImage.VirtualSize, operator, value)
+		return byteSize(c.apiImage.VirtualSize), true
 	case field == "created":
-		return durationCompare(c.full().Created, operator, value)
+		return c.full().Created, true
 	default:
 		panic(fmt.Sprintf("Invalid field %s", field))
 	}
 }
 
+func (c *DockerImage) labelKeys() []string {
+	return mapKeys(c.apiImage.Labels)
+}
+
+// listedValue returns the value of the fields known from the image listing, e.g. its tags, without inspecting it
+func (c *DockerImage) listedValue(field string) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(field, "label."):
+		labelValue, found := c.apiImage.Labels[strings.TrimPrefix(field, "label.")]
+		return labelValue, found
+	case field == "id", field == "tag":
+		return c.Value(field)
+	default:
+		return nil, false
+	}
+}
+
 func (c *DockerImage) full() *docker.Image {
 	if c.fullImage != nil {
 		return c.fullImage
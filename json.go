@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jawher/bateau/query"
+	"github.com/jawher/mow.cli"
+)
+
+/*
+jsonCommand configures the json command, which filters newline delimited JSON documents instead of docker objects,
+with the named queries of the configuration returned by cfg
+*/
+func jsonCommand(cfg func() config) func(cmd *cli.Cmd) {
+	return func(cmd *cli.Cmd) {
+		vars := cmd.StringsOpt("var", nil, "A NAME=value variable, referenced as $NAME in the query, can be repeated")
+		queryFile := cmd.StringOpt("f file", "", "Read the query from a file, not from the standard input which holds the documents")
+		queryStr := cmd.StringArg("QUERY", "", "The documents filtering query, e.g. 'level=error & user.name~admin'")
+
+		cmd.Spec = "[--var]... (-f | QUERY)"
+		cmd.Action = func() {
+			if *queryFile == "-" {
+				fail("The query cannot be read from the standard input, which holds the documents to filter")
+			}
+			matcher, err := query.Parse(queryOrFail(*queryFile, *queryStr), query.MapFields, parseOptionsOrFail(cfg(), *vars)...)
+			if err != nil {
+				fail("Invalid query: %v", err)
+			}
+			ok, err := filterJSON(os.Stdin, os.Stdout, matcher)
+			if err != nil {
+				fail("Error while filtering the documents: %v", err)
+			}
+			if !ok {
+				cli.Exit(1)
+			}
+		}
+	}
+}
+
+/*
+filterJSON copies the documents matching the query, one JSON object per line, from in to out.
+Invalid documents are reported without stopping the filtering, and make filterJSON return false.
+*/
+func filterJSON(in io.Reader, out io.Writer, matcher query.Expression) (bool, error) {
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	ok := true
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return ok, err
+		}
+		if doc := bytes.TrimSpace(line); len(doc) != 0 {
+			q, docErr := query.FromJSON(doc)
+			switch {
+			case docErr != nil:
+				fmt.Fprintf(os.Stderr, "Invalid document on line %d: %v\n", lineNumber, docErr)
+				ok = false
+			case matcher.Match(q):
+				if _, err := fmt.Fprintf(writer, "%s\n", doc); err != nil {
+					return ok, err
+				}
+			}
+		}
+		if err == io.EOF {
+			return ok, writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jawher/bateau/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterJSON(t *testing.T) {
+	matcher, err := query.Parse("level=error & duration_ms>100", query.MapFields)
+	require.NoError(t, err)
+
+	in := strings.NewReader(`{"level": "error", "duration_ms": 250, "msg": "slow"}
+{"level": "info", "duration_ms": 300}
+
+  {"level": "error", "duration_ms": 50}
+{"level": "error", "duration_ms": 101.5}`)
+	var out bytes.Buffer
+	ok, err := filterJSON(in, &out, matcher)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `{"level": "error", "duration_ms": 250, "msg": "slow"}
+{"level": "error", "duration_ms": 101.5}
+`, out.String())
+
+	out.Reset()
+	ok, err = filterJSON(strings.NewReader("not json\n[1]\n{\"level\": \"error\", \"duration_ms\": 200}\n"), &out, matcher)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, "{\"level\": \"error\", \"duration_ms\": 200}\n", out.String())
+}
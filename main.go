@@ -1,12 +1,12 @@
 package main
 
 import (
+	"io"
 	"os"
+	"strings"
 
 	"fmt"
 
-	"path/filepath"
-
 	"github.com/fsouza/go-dockerclient"
 	"github.com/jawher/bateau/query"
 	"github.com/jawher/mow.cli"
@@ -16,94 +16,237 @@ func main() {
 	app := cli.App("bateau", "Docker ps on steroids")
 	app.LongDesc = HELP
 
-	endpoint := app.StringOpt("e endpoint", "", "The docker socket path or TCP address")
+	// an invalid configuration file is only reported by the commands using it, so that --help and completion still work
+	cfg, cfgErr := loadConfig(configDir())
+	if cfgErr != nil {
+		cfg = config{}
+	}
+	configOrFail := func() config {
+		if cfgErr != nil {
+			fail("%v", cfgErr)
+		}
+		return cfg
+	}
+	defaultFormat := cfg.Format
+	if len(defaultFormat) == 0 {
+		defaultFormat = formatTSV
+	}
+
+	endpoints := app.StringsOpt("e endpoint", nil, "The docker socket path, TCP address or ssh://[user@]host, can be repeated to query several daemons")
+	contexts := app.StringsOpt("context", nil, "The docker CLI context to use, can be repeated to query several daemons")
 	_ = app.BoolOpt("c containers", true, "Filter on containers")
 	images := app.BoolOpt("i images", false, "Filter on images")
+	services := app.BoolOpt("services", false, "Filter on swarm services")
+	tasks := app.BoolOpt("tasks", false, "Filter on swarm tasks")
+	nodes := app.BoolOpt("nodes", false, "Filter on swarm nodes")
+
+	fields := app.StringOpt("fields", "", "Comma separated list of the fields to print, e.g. id,name,image,exit,created")
+	format := app.StringOpt("format", defaultFormat, "The output format: tsv, csv or json")
+	raw := app.BoolOpt("raw", false, "Print durations and sizes as raw values instead of human readable ones")
+
+	vars := app.StringsOpt("var", nil, "A NAME=value variable, referenced as $NAME in the query, can be repeated")
 
+	queryFile := app.StringOpt("f file", "", "Read the query from a file, or from the standard input for -")
 	queryStr := app.StringArg("QUERY", "", "The containers filtering query")
 
-	app.Spec = "[-e] [-c|-i] QUERY"
+	app.Spec = "[-e|--context]... [-c|-i|--services|--tasks|--nodes] [--fields] [--format] [--raw] [--var]... [-f | QUERY]"
+	app.Command("json", "Filter the JSON documents read from the standard input, one per line", jsonCommand(configOrFail))
+	app.Command("fmt", "Print a query in its canonical form", fmtCommand(configOrFail))
+	app.Command("completion", "Print the completion script of a shell: bash, zsh or fish", completionCommand())
+	app.Command("__complete", "Print the completions of a command line", completeCommand(cfg))
 	app.Action = func() {
+		cfg := configOrFail()
+		if len(*queryFile) == 0 && len(*queryStr) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: incorrect usage, a query is required")
+			app.PrintHelp()
+			cli.Exit(2)
+		}
+		t := containersTarget
 		switch {
 		case *images:
-			queryImages(*queryStr, *endpoint)
-		default:
-			queryContainers(*queryStr, *endpoint)
+			t = imagesTarget
+		case *services:
+			t = servicesTarget
+		case *tasks:
+			t = tasksTarget
+		case *nodes:
+			t = nodesTarget
+		}
+		eps, err := resolveEndpoints(cfg.endpoints(*endpoints, *contexts))
+		if err != nil {
+			fail("Invalid docker endpoint: %v", err)
 		}
+		run(t, queryOrFail(*queryFile, *queryStr), parseOptionsOrFail(cfg, *vars), eps, newOutputOrFail(*fields, *format, *raw, t.fields))
 	}
 	app.Run(os.Args)
 }
 
-func queryImages(queryStr, endpoint string) {
-	matcher, err := query.Parse(queryStr, imgFields)
+// queryOrFail returns the query read from the file provided with -f if any, or the query provided as an argument
+func queryOrFail(file, queryStr string) string {
+	if len(file) == 0 {
+		return queryStr
+	}
+	data, err := readQuery(file)
 	if err != nil {
-		fail("Invalid query: %v", err)
+		fail("Error while reading the query: %v", err)
 	}
-	client := NewDocker(endpoint)
+	return data
+}
 
-	images, err := client.ListImages(docker.ListImagesOptions{All: false})
+// parseOptionsOrFail returns the named queries from the configuration and the variables provided with --var as parse options
+func parseOptionsOrFail(cfg config, vars []string) []query.Option {
+	variables, err := parseVariables(vars)
 	if err != nil {
-		fail("Error while listing containers: %v", err)
+		fail("Invalid variable: %v", err)
 	}
-	for _, image := range images {
-		if matcher.Match(wrapImage(client, image)) {
-			fmt.Printf("%s\n", image.ID)
-		}
+	return []query.Option{
+		query.NamedQueries(cfg.Queries),
+		query.Variables(variables),
+		query.Environment(os.LookupEnv),
 	}
 }
 
-func queryContainers(queryStr, endpoint string) {
-	matcher, err := query.Parse(queryStr, conFields)
+func newOutputOrFail(fields, format string, raw bool, known map[string][]query.Operator) *output {
+	res, err := newOutput(os.Stdout, fields, format, raw, known)
 	if err != nil {
-		fail("Invalid query: %v", err)
+		fail("Invalid output options: %v", err)
 	}
-	client := NewDocker(endpoint)
+	return res
+}
+
+/*
+target describes a kind of docker objects bateau can query: their name, supported fields and their types, and how to list them
+*/
+type target struct {
+	name   string
+	fields map[string][]query.Operator
+	types  map[string]query.Type
+	list   func(client *docker.Client) ([]queryable, error)
+}
+
+// queryable is implemented by the docker objects bateau can both query and print
+type queryable interface {
+	query.SliceQueryable
+	valuer
+}
+
+var (
+	containersTarget = target{name: "containers", fields: withHost(conFields), types: withHostType(conTypes), list: listContainers}
+	imagesTarget     = target{name: "images", fields: withHost(imgFields), types: withHostType(imgTypes), list: listImages}
+	servicesTarget   = target{name: "services", fields: withHost(svcFields), types: withHostType(svcTypes), list: listServices}
+	tasksTarget      = target{name: "tasks", fields: withHost(taskFields), types: withHostType(taskTypes), list: listTasks}
+	nodesTarget      = target{name: "nodes", fields: withHost(nodeFields), types: withHostType(nodeTypes), list: listNodes}
+)
 
-	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+/*
+run queries the objects of every docker daemon and prints the matching ones.
+A daemon which cannot be listed is reported without preventing the others from being queried.
+*/
+func run(t target, queryStr string, parseOptions []query.Option, endpoints []dockerEndpoint, out *output) {
+	matcher, err := query.Parse(queryStr, t.fields, append(parseOptions, query.Types(t.types))...)
 	if err != nil {
-		fail("Error while listing containers: %v", err)
+		fail("Invalid query: %v", err)
 	}
-	for _, container := range containers {
-		if matcher.Match(wrapContainer(client, container)) {
-			fmt.Printf("%s\n", container.ID)
+
+	ok := queryEndpoints(t, matcher, endpoints, out)
+	if err := out.flush(); err != nil {
+		fail("Error while printing %s: %v", t.name, err)
+	}
+	if !ok {
+		cli.Exit(1)
+	}
+}
+
+/*
+queryEndpoints writes the matching objects of every daemon, and returns false if some of them could not be connected to
+or listed, which is reported on the standard error
+*/
+func queryEndpoints(t target, matcher query.Expression, endpoints []dockerEndpoint, out *output) bool {
+	ok := true
+	for _, ep := range endpoints {
+		client, err := newDockerClient(ep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while connecting to docker on %s: %v\n", ep.name, err)
+			ok = false
+			continue
+		}
+		if err := queryEndpoint(t, matcher, client, ep.name, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error while listing %s on %s: %v\n", t.name, ep.name, err)
+			ok = false
 		}
 	}
+	return ok
 }
 
-func fail(msg string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, msg+"\n", args...)
-	cli.Exit(1)
+func queryEndpoint(t target, matcher query.Expression, client *docker.Client, host string, out *output) error {
+	objects, err := t.list(client)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		obj = &hostQueryable{host: host, queryable: obj}
+		if matcher.Match(obj) {
+			if err := out.write(obj); err != nil {
+				fail("Error while printing %s: %v", t.name, err)
+			}
+		}
+	}
+	return nil
 }
 
-func NewDocker(endpoint string) *docker.Client {
-	endpoint = resolveDockerEndpoint(endpoint)
+// readQuery reads a query from a file, or from the standard input for -
+func readQuery(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	return string(data), err
+}
 
-	if len(os.Getenv("DOCKER_TLS_VERIFY")) != 0 {
-		client, err := docker.NewTLSClient(endpoint,
-			filepath.Join(os.Getenv("DOCKER_CERT_PATH"), "cert.pem"),
-			filepath.Join(os.Getenv("DOCKER_CERT_PATH"), "key.pem"),
-			filepath.Join(os.Getenv("DOCKER_CERT_PATH"), "ca.pem"))
-		if err != nil {
-			fail("Error while connecting to docker: %v", err)
+// parseVariables parses the NAME=value variables provided with --var
+func parseVariables(vars []string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, v := range vars {
+		name, value, found := strings.Cut(v, "=")
+		if !found || len(name) == 0 {
+			return nil, fmt.Errorf("%s should be NAME=value", v)
 		}
-
-		return client
+		res[name] = value
 	}
-	client, err := docker.NewClient(endpoint)
-	if err != nil {
-		fail("Error while connecting to docker: %v", err)
+	return res, nil
+}
+
+// nonEmpty returns a slice containing s, or an empty slice if s is empty
+func nonEmpty(s string) []string {
+	if len(s) == 0 {
+		return nil
 	}
-	return client
+	return []string{s}
 }
 
-func resolveDockerEndpoint(input string) string {
-	if len(input) != 0 {
-		return input
+func fail(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	cli.Exit(1)
+}
+
+// newDockerClient returns a client of the endpoint daemon, or the error preventing to connect to it, e.g. a missing TLS certificate
+func newDockerClient(ep dockerEndpoint) (*docker.Client, error) {
+	if isSSH(ep.host) {
+		return newSSHClient(ep.host)
 	}
-	if len(os.Getenv("DOCKER_HOST")) != 0 {
-		return os.Getenv("DOCKER_HOST")
+	if ep.tls {
+		client, err := docker.NewTLSClient(ep.host, ep.cert, ep.key, ep.ca)
+		if err != nil {
+			return nil, err
+		}
+		// go-dockerclient skips the server verification when no CA is provided: verify against the system CAs instead
+		client.TLSConfig.InsecureSkipVerify = ep.skipVerify
+		return client, nil
 	}
-	return "unix:///var/run/docker.sock"
+	return docker.NewClient(ep.host)
 }
 
 const HELP = `Docker ps on steroids.
@@ -111,7 +254,9 @@ const HELP = `Docker ps on steroids.
 Container fields:
 * running, paused, restarting: booleans. e.g. 'running', 'running | paused'
 * label.<label-name>: boolean to test for existence, e.g. 'label.arch' or string to test value, e.g. 'label.arch=amd64'
+* image.label.<label-name>: the labels of the container image, e.g. 'image.label.maintainer~infra'
 * id, name, image. cmd, entrypoint: string, e.g. 'entrypoint~bash'
+* network: string, the names of the networks the container is connected to, e.g. 'network=backend'
 * exit: int, e.g. 'exit=1' or 'exit>0'
 * created, exited: duration, e.g. 'created>2w'
 
@@ -121,6 +266,49 @@ Image fields:
 * size: size, e.g. 'size>200MB'
 * created: duration, e.g. 'created>2w'
 
+Service fields:
+* id, name, mode, image: string, e.g. 'mode=global'
+* label.<label-name>: boolean to test for existence or string to test value
+* replicas: int, e.g. 'replicas>3'
+* created, updated: duration, e.g. 'updated<1d'
+
+Task fields:
+* id, state, desired_state, error: string, e.g. 'state!=running & desired_state=running'
+* service, node: string, the service name and the node hostname, e.g. 'service=web'
+* created: duration, e.g. 'created<1h'
+
+Node fields:
+* id, hostname, role, availability, status, engine_version: string, e.g. 'role=manager & availability!=active'
+* label.<label-name>: boolean to test for existence or string to test value
+* created: duration, e.g. 'created>1y'
+
+All the objects also have a host field, naming the docker daemon (context or address host) they come from,
+e.g. 'host=build-3 & exit!=0' when querying several daemons with -e or --context.
+
+JSON documents:
+'bateau json QUERY' filters the JSON documents read from the standard input, one per line, and prints the matching ones,
+e.g. 'kubectl get pods -o json | jq -c ".items[]" | bateau json "status.phase!=Running"'.
+Fields are dotted paths, and paths going through arrays match if any value matches, e.g. 'items.price>10'.
+
+Formatting:
+'bateau fmt QUERY' prints a query in its canonical form, with normalized spacing, only the required parenthesis and quotes,
+e.g. 'bateau fmt "( running and name ~ web ) or exit>0"' prints 'running & name~web | exit>0'.
+'bateau fmt -w -f retention.bq' rewrites a query file in place, unless it has comments or named query references,
+which the formatted query would lose as the named queries are expanded and the comments dropped.
+
+Shell completion:
+'source <(bateau completion bash)', or zsh, and 'bateau completion fish | source' complete the field names, their operators
+and their values, read from the docker daemons, e.g. 'bateau "label.env=<TAB>' lists the values of the env label.
+
+Query files:
+Long queries can be read from a file with -f, e.g. 'bateau -f retention.bq', or from the standard input with '-f=-'.
+Queries can span several lines, and # starts a comment running to the end of the line.
+
+Named queries:
+Queries can be saved by name in ~/.config/bateau/config.toml (or config.yaml), e.g. stale = "created>2w & !running",
+and referenced with @name, on their own or inside other queries, e.g. '@stale' or '@stale & label.team=infra'.
+The configuration file can also set the default endpoint or context and output format.
+
 Duration units:
 ms->milliseconds, s->seconds, m->minutes, h->hours, d->days, w->weeks, M,months->months, y->years
 
@@ -128,9 +316,25 @@ Size units:
 KB->1024, MB->1024MB, GB->1024MB
 Kb->1000, Mb->1000Mb, Gb->1000Mb
 
+Output:
+--fields selects the fields to print (default: id), using the same names as in queries, e.g. 'id,name,label.arch'
+--format prints them as tsv, csv or json, and --raw prints durations and sizes as timestamps and bytes
+
 Operators:
 '=' : exact equality, '~' : case-insensitive contains, '!=' : exact inequality, '!~' : inverse of ~
 '>', '>=', '<', '<=' : numeric comparison
-'|' : logical or, '&' : logical and, '!' : logical not
+'|', 'or' : logical or, '&', 'and' : logical and, '!', 'not' : logical not
 '(', ')' : to control precedence
+'$field' : compare to another field instead of a value, e.g. 'label.version!=$image.label.version'
+'$NAME' : the value of a variable, set with --var NAME=value or from the environment, e.g. 'name=$APP & created>$MAX_AGE'
+'+', '-', '*', '/' : compute the value from numbers, durations, sizes and fields, surrounded by spaces, e.g. 'exited > $created - 1h'
+'is null', 'is not null' : test whether a field has no value, e.g. 'exit is null'; other conditions on missing values never match, even negated
+'any(...)', 'all(...)', 'none(...)' : match if any, all or none of the values of a multi-valued field match, e.g. 'all(tag~registry.local)'
+
+Functions:
+'len(field)' : the number of values of a multi-valued field (cmd, entrypoint, network, tag) or the length of a string field, e.g. 'len(name)>40'
+'count(field)' : the number of values of a multi-valued field, e.g. 'count(network)>=2'
+'lower(field)', 'upper(field)', 'trim(field)' : the string field in lower case, upper case or without surrounding spaces, e.g. 'lower(label.env)=prod'
+'basename(field)' : the last part of a path, e.g. 'basename(image)=nginx:1.25'
+'split(field, separator)[index]' : the part of a string at index, negative indexes counting from the end, e.g. 'split(image, ":")[1]=latest'
 `
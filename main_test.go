@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jawher/bateau/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVariables(t *testing.T) {
+	vars, err := parseVariables([]string{"APP=web", "FILTER=a=b | c", "EMPTY="})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"APP": "web", "FILTER": "a=b | c", "EMPTY": ""}, vars)
+
+	for _, invalid := range []string{"APP", "=web"} {
+		_, err := parseVariables([]string{invalid})
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestReadQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retention.bq")
+	require.NoError(t, os.WriteFile(path, []byte("# stale\ncreated > 2w\n& !running\n"), 0644))
+
+	q, err := readQuery(path)
+	require.NoError(t, err)
+	require.Equal(t, "# stale\ncreated > 2w\n& !running\n", q)
+
+	_, err = query.Parse(q, conFields, query.Types(conTypes))
+	require.NoError(t, err)
+
+	_, err = readQuery(filepath.Join(t.TempDir(), "missing.bq"))
+	require.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/jawher/bateau/query"
+)
+
+var (
+	nodeFields = map[string][]query.Operator{
+		"label.*": {query.IS, query.EQ, query.LIKE},
+
+		"id":             {query.EQ, query.LIKE},
+		"hostname":       {query.EQ, query.LIKE},
+		"role":           {query.EQ, query.LIKE},
+		"availability":   {query.EQ, query.LIKE},
+		"status":         {query.EQ, query.LIKE},
+		"engine_version": {query.EQ, query.LIKE},
+
+		"created": orderOperators,
+	}
+
+	nodeTypes = map[string]query.Type{
+		"label.*": query.TypeString,
+
+		"id":             query.TypeString,
+		"hostname":       query.TypeString,
+		"role":           query.TypeString,
+		"availability":   query.TypeString,
+		"status":         query.TypeString,
+		"engine_version": query.TypeString,
+
+		"created": query.TypeDuration,
+	}
+)
+
+type DockerNode struct {
+	node swarm.Node
+}
+
+var _ query.SliceQueryable = &DockerNode{}
+
+func listNodes(client *docker.Client) ([]queryable, error) {
+	nodes, err := client.ListNodes(docker.ListNodesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]queryable, len(nodes))
+	for i, node := range nodes {
+		res[i] = &DockerNode{node: node}
+	}
+	return res, nil
+}
+
+func (n *DockerNode) labelKeys() []string {
+	return mapKeys(n.node.Spec.Labels)
+}
+
+func (n *DockerNode) Is(field string, operator query.Operator, value string) bool {
+	v, found := n.Value(field)
+	return valueCompare(v, found, operator, value)
+}
+
+func (n *DockerNode) Elements(field string) []query.Queryable {
+	return elements(n, field)
+}
+
+/*
+Value returns the value of the provided field, or false if the node has no value for it.
+*/
+func (n *DockerNode) Value(field string) (interface{}, bool) {
+	switch {
+	case strings.HasPrefix(field, "label."):
+		label := strings.TrimPrefix(field, "label.")
+		labelValue, found := n.node.Spec.Labels[label]
+		return labelValue, found
+	case field == "id":
+		return n.node.ID, true
+	case field == "hostname":
+		return n.node.Description.Hostname, true
+	case field == "role":
+		return string(n.node.Spec.Role), true
+	case field == "availability":
+		return string(n.node.Spec.Availability), true
+	case field == "status":
+		return string(n.node.Status.State), true
+	case field == "engine_version":
+		return n.node.Description.Engine.EngineVersion, true
+	case field == "created":
+		return n.node.CreatedAt, true
+	default:
+		panic(fmt.Sprintf("Invalid field %s", field))
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jawher/bateau/query"
+)
+
+const (
+	formatTSV  = "tsv"
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+// valuer is implemented by the docker objects bateau can print, e.g. DockerContainer and DockerImage
+type valuer interface {
+	Value(field string) (interface{}, bool)
+}
+
+/*
+output prints the selected fields of the matched docker objects.
+Fields are resolved using the objects Value method, i.e. the same way as when they are queried.
+*/
+type output struct {
+	fields []string
+	format string
+	raw    bool
+
+	out io.Writer
+	csv *csv.Writer
+}
+
+/*
+newOutput validates the comma separated fields list against the known fields and the output format.
+An empty fields list selects the id field only.
+*/
+func newOutput(out io.Writer, fields, format string, raw bool, known map[string][]query.Operator) (*output, error) {
+	res := &output{
+		format: format,
+		raw:    raw,
+		out:    out,
+	}
+
+	if len(strings.TrimSpace(fields)) == 0 {
+		fields = "id"
+	}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if _, found := query.LookupField(known, field); !found {
+			return nil, fmt.Errorf("Unknown field '%s'", field)
+		}
+		res.fields = append(res.fields, field)
+	}
+
+	switch format {
+	case formatTSV, formatJSON:
+	case formatCSV:
+		res.csv = csv.NewWriter(out)
+		if err := res.csv.Write(res.fields); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown output format '%s', should be one of %s, %s or %s", format, formatTSV, formatCSV, formatJSON)
+	}
+	return res, nil
+}
+
+func (o *output) write(v valuer) error {
+	switch o.format {
+	case formatJSON:
+		return o.writeJSON(v)
+	case formatCSV:
+		return o.csv.Write(o.row(v))
+	default:
+		_, err := fmt.Fprintln(o.out, strings.Join(o.row(v), "\t"))
+		return err
+	}
+}
+
+func (o *output) flush() error {
+	if o.csv == nil {
+		return nil
+	}
+	o.csv.Flush()
+	return o.csv.Error()
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+func (o *output) row(v valuer) []string {
+	res := make([]string, len(o.fields))
+	for i, field := range o.fields {
+		value, found := v.Value(field)
+		if !found {
+			continue
+		}
+		res[i] = o.text(value)
+		if o.format == formatTSV {
+			res[i] = tsvEscaper.Replace(res[i])
+		}
+	}
+	return res
+}
+
+func (o *output) writeJSON(v valuer) error {
+	var buffer []string
+	for _, field := range o.fields {
+		var value interface{}
+		if fv, found := v.Value(field); found {
+			value = o.jsonValue(fv)
+		}
+		k, err := json.Marshal(field)
+		if err != nil {
+			return err
+		}
+		jv, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buffer = append(buffer, string(k)+":"+string(jv))
+	}
+	_, err := fmt.Fprintf(o.out, "{%s}\n", strings.Join(buffer, ","))
+	return err
+}
+
+func (o *output) text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	case time.Time:
+		if o.raw {
+			return v.Format(time.RFC3339)
+		}
+		return query.FormatDuration(durationBaseTime().Sub(v))
+	case byteSize:
+		if o.raw {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return query.FormatSize(int64(v))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func (o *output) jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		if o.raw {
+			return v.Format(time.RFC3339)
+		}
+		return query.FormatDuration(durationBaseTime().Sub(v))
+	case byteSize:
+		if o.raw {
+			return int64(v)
+		}
+		return query.FormatSize(int64(v))
+	default:
+		return v
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jawher/bateau/query"
+	"github.com/stretchr/testify/require"
+)
+
+type mapValuer map[string]interface{}
+
+func (m mapValuer) Value(field string) (interface{}, bool) {
+	v, found := m[field]
+	return v, found
+}
+
+var outputFields = map[string][]query.Operator{
+	"id":      {query.EQ},
+	"cmd":     {query.EQ},
+	"exit":    {query.EQ, query.GT},
+	"created": {query.EQ, query.GT},
+	"size":    {query.EQ, query.GT},
+	"label.*": {query.IS, query.EQ},
+}
+
+func TestOutput(t *testing.T) {
+	base := time.Date(2015, 11, 23, 10, 0, 0, 0, time.UTC)
+	originalDurationBaseTime := durationBaseTime
+	durationBaseTime = func() time.Time {
+		return base
+	}
+	defer func() {
+		durationBaseTime = originalDurationBaseTime
+	}()
+
+	obj := mapValuer{
+		"id":          "abc",
+		"cmd":         []string{"sh", "-c", "echo\tok"},
+		"exit":        0,
+		"created":     base.Add(-17 * 24 * time.Hour),
+		"size":        byteSize(42 * 1024 * 1024),
+		"label.owner": "jawher",
+	}
+
+	cases := []struct {
+		fields, format string
+		raw            bool
+		expected       string
+	}{
+		{"", formatTSV, false, "abc\n"},
+		{"id,exit,label.owner,label.missing", formatTSV, false, "abc\t0\tjawher\t\n"},
+		{"cmd", formatTSV, false, "sh -c echo\\tok\n"},
+		{"created,size", formatTSV, false, "2w 3d\t42MB\n"},
+		{"created,size", formatTSV, true, "2015-11-06T10:00:00Z\t44040192\n"},
+		{"id, exit", formatCSV, false, "id,exit\nabc,0\n"},
+		{"id,cmd,size,label.missing", formatJSON, false, `{"id":"abc","cmd":["sh","-c","echo\tok"],"size":"42MB","label.missing":null}` + "\n"},
+		{"exit,size", formatJSON, true, `{"exit":0,"size":44040192}` + "\n"},
+	}
+
+	for _, cas := range cases {
+		var buffer bytes.Buffer
+		out, err := newOutput(&buffer, cas.fields, cas.format, cas.raw, outputFields)
+		require.NoError(t, err)
+
+		require.NoError(t, out.write(obj))
+		require.NoError(t, out.flush())
+		require.Equal(t, cas.expected, buffer.String(), "fields '%s' in %s", cas.fields, cas.format)
+	}
+}
+
+func TestOutputErrors(t *testing.T) {
+	_, err := newOutput(&bytes.Buffer{}, "id,nope", formatTSV, false, outputFields)
+	require.Error(t, err)
+
+	_, err = newOutput(&bytes.Buffer{}, "id", "xml", false, outputFields)
+	require.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// now is the time the age of the time values is computed against in arithmetic expressions
+var now = time.Now
+
+// LiteralOperand is a number, duration or size in an arithmetic expression, e.g. 1h in "$created - 1h"
+type LiteralOperand struct {
+	Text string
+}
+
+func (l *LiteralOperand) eval(queryable ValueQueryable) (interface{}, bool) {
+	value, _, err := l.typed()
+	return value, err == nil
+}
+
+func (l *LiteralOperand) String() string {
+	return formatOperand(l)
+}
+
+/*
+typed parses the literal as an integer, a duration or a size, in that order, and returns its value and type
+*/
+func (l *LiteralOperand) typed() (interface{}, Type, error) {
+	if i, err := strconv.ParseInt(l.Text, 10, 64); err == nil {
+		return i, TypeInt, nil
+	}
+	if d, err := ParseDuration(l.Text); err == nil {
+		return d, TypeDuration, nil
+	}
+	if s, err := ParseSize(l.Text); err == nil {
+		return s, TypeSize, nil
+	}
+	return nil, TypeAny, fmt.Errorf("%s is neither a number, a duration nor a size", l.Text)
+}
+
+/*
+ArithOperand is the result of an arithmetic operation on integers, sizes and durations, e.g. "$created - 1h" or "2 * 500MB".
+Time values are converted to their age, like in created>2w.
+*/
+type ArithOperand struct {
+	Operator    string
+	Left, Right Operand
+}
+
+func (a *ArithOperand) String() string {
+	return formatOperand(a)
+}
+
+func (a *ArithOperand) eval(queryable ValueQueryable) (interface{}, bool) {
+	left, found := a.Left.eval(queryable)
+	if !found {
+		return nil, false
+	}
+	right, found := a.Right.eval(queryable)
+	if !found {
+		return nil, false
+	}
+	l, lDuration, ok := arithValue(left)
+	if !ok {
+		return nil, false
+	}
+	r, rDuration, ok := arithValue(right)
+	if !ok {
+		return nil, false
+	}
+
+	var res int64
+	switch a.Operator {
+	case "+":
+		res = l + r
+	case "-":
+		res = l - r
+	case "*":
+		res = l * r
+	case "/":
+		if r == 0 {
+			return nil, false
+		}
+		res = l / r
+	}
+	// a duration multiplied or divided by a number is a duration, while the ratio of two durations is a number
+	if lDuration != rDuration || (lDuration && a.Operator != "/") {
+		return time.Duration(res), true
+	}
+	return res, true
+}
+
+// arithValue returns the integer value of a number, size, duration or time, and whether it is a duration
+func arithValue(value interface{}) (int64, bool, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return int64(now().Sub(v)), true, true
+	case time.Duration:
+		return int64(v), true, true
+	}
+	i, ok := toInt(value)
+	return i, false, ok
+}
+
+/*
+arithType returns the type of the result of an arithmetic operation, or false if it is not supported by the operand types:
+numbers and sizes can be added together, sizes and durations multiplied or divided by numbers,
+and dividing two sizes or durations gives a number
+*/
+func arithType(operator string, left, right Type) (Type, bool) {
+	numeric := func(t Type) bool {
+		return t == TypeInt || t == TypeSize || t == TypeDuration
+	}
+	switch {
+	case left == TypeAny || right == TypeAny:
+		return TypeAny, true
+	case !numeric(left) || !numeric(right):
+		return TypeAny, false
+	}
+
+	switch operator {
+	case "+", "-":
+		switch {
+		case left == right:
+			return left, true
+		case left != TypeDuration && right != TypeDuration:
+			return TypeSize, true
+		}
+	case "*":
+		switch {
+		case left == TypeInt:
+			return right, true
+		case right == TypeInt:
+			return left, true
+		}
+	case "/":
+		switch {
+		case right == TypeInt:
+			return left, true
+		case left == right:
+			return TypeInt, true
+		}
+	}
+	return TypeAny, false
+}
@@ -0,0 +1,134 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func literal(text string) *LiteralOperand {
+	return &LiteralOperand{Text: text}
+}
+
+func TestLiteralTyped(t *testing.T) {
+	cases := []struct {
+		text  string
+		typ   Type
+		value interface{}
+	}{
+		{"42", TypeInt, int64(42)},
+		{"1h", TypeDuration, time.Hour},
+		{"2M", TypeDuration, 60 * 24 * time.Hour},
+		{"500MB", TypeSize, int64(500 * 1024 * 1024)},
+		{"1Kb", TypeSize, int64(1000)},
+	}
+
+	for _, cas := range cases {
+		value, typ, err := (&LiteralOperand{Text: cas.text}).typed()
+		require.NoError(t, err, cas.text)
+		require.Equal(t, cas.typ, typ, cas.text)
+		require.Equal(t, cas.value, value, cas.text)
+	}
+
+	_, _, err := (&LiteralOperand{Text: "abc"}).typed()
+	require.Error(t, err)
+
+	// literals built without Parse are evaluated from their text
+	value, found := (&LiteralOperand{Text: "1h"}).eval(nil)
+	require.True(t, found)
+	require.Equal(t, time.Hour, value)
+	_, found = (&LiteralOperand{Text: "abc"}).eval(nil)
+	require.False(t, found)
+}
+
+func TestArithEval(t *testing.T) {
+	base := time.Now()
+	now = func() time.Time { return base }
+	defer func() { now = time.Now }()
+
+	q := valueQueryable{
+		"created": base.Add(-3 * time.Hour),
+		"size":    byteSize(1024),
+		"name":    "web",
+	}
+	ref := func(field string) *RefOperand {
+		return &RefOperand{FieldOperand{Field: field}}
+	}
+
+	cases := []struct {
+		arith    *ArithOperand
+		expected interface{}
+	}{
+		{&ArithOperand{Operator: "+", Left: literal("1"), Right: literal("2")}, int64(3)},
+		{&ArithOperand{Operator: "*", Left: literal("2"), Right: literal("500MB")}, int64(1000 * 1024 * 1024)},
+		{&ArithOperand{Operator: "-", Left: ref("size"), Right: literal("1KB")}, int64(0)},
+		{&ArithOperand{Operator: "/", Left: ref("size"), Right: literal("2")}, int64(512)},
+		{&ArithOperand{Operator: "-", Left: ref("created"), Right: literal("1h")}, 2 * time.Hour},
+		{&ArithOperand{Operator: "*", Left: literal("2"), Right: literal("1h")}, 2 * time.Hour},
+		{&ArithOperand{Operator: "/", Left: ref("created"), Right: literal("1h")}, int64(3)},
+		{&ArithOperand{Operator: "/", Left: ref("created"), Right: literal("2")}, 90 * time.Minute},
+	}
+
+	for _, cas := range cases {
+		res, found := cas.arith.eval(q)
+		require.True(t, found, "%v", cas.arith)
+		require.Equal(t, cas.expected, res, "%v", cas.arith)
+	}
+
+	// missing fields, values of other types and divisions by zero have no value
+	for _, arith := range []*ArithOperand{
+		{Operator: "+", Left: ref("missing"), Right: literal("1")},
+		{Operator: "+", Left: ref("name"), Right: literal("1")},
+		{Operator: "/", Left: literal("1"), Right: literal("0")},
+	} {
+		_, found := arith.eval(q)
+		require.False(t, found, "%v", arith)
+	}
+
+	exited := valueQueryable{
+		"created": base.Add(-3 * time.Hour),
+		"exited":  base.Add(-150 * time.Minute),
+	}
+	crashed := &ValueExpr{
+		Left:     field("exited"),
+		Operator: ">",
+		Right:    &ArithOperand{Operator: "-", Left: ref("created"), Right: literal("1h")},
+	}
+	require.True(t, crashed.Match(exited))
+	exited["exited"] = base.Add(-time.Hour)
+	require.False(t, crashed.Match(exited))
+}
+
+func TestArithType(t *testing.T) {
+	cases := []struct {
+		left     Type
+		operator string
+		right    Type
+		expected Type
+		ok       bool
+	}{
+		{TypeInt, "+", TypeInt, TypeInt, true},
+		{TypeSize, "+", TypeInt, TypeSize, true},
+		{TypeInt, "-", TypeSize, TypeSize, true},
+		{TypeDuration, "-", TypeDuration, TypeDuration, true},
+		{TypeInt, "*", TypeSize, TypeSize, true},
+		{TypeDuration, "*", TypeInt, TypeDuration, true},
+		{TypeDuration, "/", TypeInt, TypeDuration, true},
+		{TypeSize, "/", TypeSize, TypeInt, true},
+		{TypeAny, "+", TypeString, TypeAny, true},
+
+		{TypeDuration, "+", TypeInt, TypeAny, false},
+		{TypeDuration, "+", TypeSize, TypeAny, false},
+		{TypeSize, "*", TypeSize, TypeAny, false},
+		{TypeInt, "/", TypeDuration, TypeAny, false},
+		{TypeString, "+", TypeInt, TypeAny, false},
+		{TypeList, "*", TypeInt, TypeAny, false},
+	}
+
+	for _, cas := range cases {
+		res, ok := arithType(cas.operator, cas.left, cas.right)
+		require.Equal(t, cas.ok, ok, "%s %s %s", cas.left, cas.operator, cas.right)
+		require.Equal(t, cas.expected, res, "%s %s %s", cas.left, cas.operator, cas.right)
+	}
+}
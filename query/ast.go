@@ -3,87 +3,286 @@ package query
 import "fmt"
 
 /*
-Expression is a predicate which can be applied to a queryable
+Expression is a predicate which can be applied to a queryable.
+The expressions returned by Parse are trees of OrExpr, AndExpr, NotExpr and QuantExpr nodes,
+whose leaves are CompExpr, NullExpr and ValueExpr conditions, and can be inspected with Walk.
 */
 type Expression interface {
-	// Match accepts or rejects a queryable depending on the expression implementation
+	// Match accepts or rejects a queryable depending on the expression implementation, i.e. if Eval returns True
 	Match(queryable Queryable) bool
+	// Eval evaluates the expression against a queryable, which may be Unknown when it depends on missing values
+	Eval(queryable Queryable) Truth
 }
 
-type exprOr struct {
-	left, right Expression
+/*
+Truth is the result of an expression evaluation, using three-valued logic: a condition on a missing value,
+e.g. exit=0 on a container which never ran, is Unknown, and so is its negation.
+*/
+type Truth int
+
+const (
+	False Truth = iota
+	True
+	Unknown
+)
+
+func (t Truth) String() string {
+	switch t {
+	case False:
+		return "false"
+	case True:
+		return "true"
+	default:
+		return "unknown"
+	}
+}
+
+// truth converts a boolean to a Truth
+func truth(b bool) Truth {
+	if b {
+		return True
+	}
+	return False
 }
 
-func (or *exprOr) String() string {
-	return fmt.Sprintf("(%v | %v)", or.left, or.right)
+// OrExpr is the disjunction of two expressions, e.g. running | paused
+type OrExpr struct {
+	Left, Right Expression
 }
 
-func (or *exprOr) Match(queryable Queryable) bool {
-	if or.left.Match(queryable) {
-		return true
+func (or *OrExpr) String() string {
+	return Format(or)
+}
+
+func (or *OrExpr) Match(queryable Queryable) bool {
+	return or.Eval(queryable) == True
+}
+
+// Eval is True if either side is True, False if both are False, and Unknown otherwise
+func (or *OrExpr) Eval(queryable Queryable) Truth {
+	left := or.Left.Eval(queryable)
+	if left == True {
+		return True
 	}
-	return or.right.Match(queryable)
+	right := or.Right.Eval(queryable)
+	switch {
+	case right == True:
+		return True
+	case left == Unknown || right == Unknown:
+		return Unknown
+	default:
+		return False
+	}
+}
+
+// AndExpr is the conjunction of two expressions, e.g. running & name=web
+type AndExpr struct {
+	Left, Right Expression
 }
 
-type exprAnd struct {
-	left, right Expression
+func (and *AndExpr) String() string {
+	return Format(and)
 }
 
-func (and *exprAnd) String() string {
-	return fmt.Sprintf("(%v & %v)", and.left, and.right)
+func (and *AndExpr) Match(queryable Queryable) bool {
+	return and.Eval(queryable) == True
 }
 
-func (and *exprAnd) Match(queryable Queryable) bool {
-	if !and.left.Match(queryable) {
-		return false
+// Eval is False if either side is False, True if both are True, and Unknown otherwise
+func (and *AndExpr) Eval(queryable Queryable) Truth {
+	left := and.Left.Eval(queryable)
+	if left == False {
+		return False
 	}
-	return and.right.Match(queryable)
+	right := and.Right.Eval(queryable)
+	switch {
+	case right == False:
+		return False
+	case left == Unknown || right == Unknown:
+		return Unknown
+	default:
+		return True
+	}
+}
+
+// NotExpr is the negation of an expression, e.g. !running
+type NotExpr struct {
+	Expression Expression
+}
+
+func (not *NotExpr) String() string {
+	return Format(not)
+}
+
+func (not *NotExpr) Match(queryable Queryable) bool {
+	return not.Eval(queryable) == True
 }
 
-type exprNot struct {
-	expression Expression
+func (not *NotExpr) Eval(queryable Queryable) Truth {
+	return negate(not.Expression.Eval(queryable))
 }
 
-func (not *exprNot) String() string {
-	return fmt.Sprintf("!(%v)", not.expression)
+// negate returns the negation of a truth, Unknown staying Unknown
+func negate(t Truth) Truth {
+	switch t {
+	case True:
+		return False
+	case False:
+		return True
+	default:
+		return Unknown
+	}
+}
+
+/*
+CompExpr tests a field, e.g. running, or compares it to a literal value, e.g. name~web.
+Operator is one of "", "=", "!=", "~", "!~", ">", ">=", "<" and "<=".
+*/
+type CompExpr struct {
+	Field    string
+	Operator string
+	Value    string
+	/*
+		Derived is true if the "!=", ">=", "<" and "<=" operators are derived from EQ and GT, for the fields which do not
+		declare NE, GE, LT and LE in the fields map passed to Parse. Otherwise, the queryable is asked for them.
+	*/
+	Derived bool
 }
 
-func (not *exprNot) Match(queryable Queryable) bool {
-	return !not.expression.Match(queryable)
+func (c *CompExpr) String() string {
+	return Format(c)
 }
 
-type exprComp struct {
-	field    string
-	operator string
-	value    string
+func (c *CompExpr) Match(queryable Queryable) bool {
+	return c.Eval(queryable) == True
 }
 
-func (c *exprComp) String() string {
-	return fmt.Sprintf("%s%s'%v'", c.field, c.operator, c.value)
+/*
+Eval is Unknown if the field is missing, for queryables implementing ValueQueryable, except when testing for the field
+existence, e.g. label.arch, which is False
+*/
+func (c *CompExpr) Eval(queryable Queryable) Truth {
+	if vq, ok := queryable.(ValueQueryable); ok && len(c.Operator) != 0 {
+		if _, found := vq.Value(c.Field); !found {
+			return Unknown
+		}
+	}
+	return truth(compare(c.Operator, !c.Derived, func(operator Operator) bool {
+		return queryable.Is(c.Field, operator, c.Value)
+	}))
 }
 
-func (c *exprComp) Match(queryable Queryable) bool {
-	if len(c.operator) == 0 {
-		return queryable.Is(c.field, IS, "")
+/*
+compare evaluates a comparison operator, or the absence of one, in terms of the IS, EQ, LIKE and GT queryable operators,
+unless native is true, in which case the NE, LT, GE and LE operators are passed as is
+*/
+func compare(operator string, native bool, is func(operator Operator) bool) bool {
+	if native && operator != "!~" {
+		return is(Operator(operator))
 	}
-	switch c.operator {
+	switch operator {
+	case "":
+		return is(IS)
 	case "=":
-		return queryable.Is(c.field, EQ, c.value)
+		return is(EQ)
 	case "!=":
-		return !queryable.Is(c.field, EQ, c.value)
+		return !is(EQ)
 	case "~":
-		return queryable.Is(c.field, LIKE, c.value)
+		return is(LIKE)
 	case "!~":
-		return !queryable.Is(c.field, LIKE, c.value)
+		return !is(LIKE)
 	case ">":
-		return queryable.Is(c.field, GT, c.value)
+		return is(GT)
 	case ">=":
-		return queryable.Is(c.field, GT, c.value) || queryable.Is(c.field, EQ, c.value)
+		return is(GT) || is(EQ)
 	case "<":
-		return !queryable.Is(c.field, GT, c.value) && !queryable.Is(c.field, EQ, c.value)
+		return !is(GT) && !is(EQ)
 	case "<=":
-		return !queryable.Is(c.field, GT, c.value)
+		return !is(GT)
 	default:
-		panic(fmt.Sprintf("Operator %s is not implemented", c.operator))
+		panic(fmt.Sprintf("Operator %s is not implemented", operator))
+	}
+}
+
+/*
+NullExpr tests whether a field is missing, e.g. exit is null, or present, e.g. exited is not null.
+Its evaluation is never Unknown.
+For the queryables which do not implement ValueQueryable, a field is missing if Is(field, IS) is false.
+*/
+type NullExpr struct {
+	Field   string
+	Negated bool
+}
+
+func (n *NullExpr) String() string {
+	return Format(n)
+}
+
+func (n *NullExpr) Match(queryable Queryable) bool {
+	return n.Eval(queryable) == True
+}
+
+func (n *NullExpr) Eval(queryable Queryable) Truth {
+	var found bool
+	if vq, ok := queryable.(ValueQueryable); ok {
+		_, found = vq.Value(n.Field)
+	} else {
+		found = queryable.Is(n.Field, IS, "")
+	}
+	return truth(found == n.Negated)
+}
+
+const (
+	quantAny  = "any"
+	quantAll  = "all"
+	quantNone = "none"
+)
+
+/*
+QuantExpr applies an expression to every element of a slice field, and matches if any, all or none of them match
+*/
+type QuantExpr struct {
+	Quantifier string
+	Field      string
+	Expression Expression
+}
+
+func (q *QuantExpr) String() string {
+	return Format(q)
+}
+
+func (q *QuantExpr) Match(queryable Queryable) bool {
+	return q.Eval(queryable) == True
+}
+
+/*
+Eval is True if any, all or none of the elements match, False if they do not, and Unknown if this depends on the elements
+whose evaluation is Unknown
+*/
+func (q *QuantExpr) Eval(queryable Queryable) Truth {
+	elements := []Queryable{queryable}
+	if sq, ok := queryable.(SliceQueryable); ok {
+		elements = sq.Elements(q.Field)
+	}
+
+	// all stops on the first False element, while any and none stop on the first True one
+	stop, res := True, False
+	if q.Quantifier == quantAll {
+		stop, res = False, True
+	}
+	for _, element := range elements {
+		t := q.Expression.Eval(element)
+		if t == stop {
+			res = stop
+			break
+		}
+		if t == Unknown {
+			res = Unknown
+		}
+	}
+
+	if q.Quantifier == quantNone {
+		return negate(res)
 	}
+	return res
 }
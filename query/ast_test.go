@@ -14,6 +14,46 @@ func (c constAst) Match(queryable Queryable) bool {
 	return bool(c)
 }
 
+func (c constAst) Eval(queryable Queryable) Truth {
+	return truth(bool(c))
+}
+
+type truthAst Truth
+
+func (c truthAst) Match(queryable Queryable) bool {
+	return Truth(c) == True
+}
+
+func (c truthAst) Eval(queryable Queryable) Truth {
+	return Truth(c)
+}
+
+func TestThreeValuedLogic(t *testing.T) {
+	const F, T, U = False, True, Unknown
+	cases := []struct{ left, right, or, and Truth }{
+		{F, F, F, F},
+		{F, T, T, F},
+		{F, U, U, F},
+		{T, F, T, F},
+		{T, T, T, T},
+		{T, U, T, U},
+		{U, F, U, F},
+		{U, T, T, U},
+		{U, U, U, U},
+	}
+
+	for _, cas := range cases {
+		left, right := truthAst(cas.left), truthAst(cas.right)
+		require.Equal(t, cas.or, (&OrExpr{left, right}).Eval(nil), "%v | %v", cas.left, cas.right)
+		require.Equal(t, cas.and, (&AndExpr{left, right}).Eval(nil), "%v & %v", cas.left, cas.right)
+	}
+
+	require.Equal(t, F, (&NotExpr{truthAst(T)}).Eval(nil))
+	require.Equal(t, T, (&NotExpr{truthAst(F)}).Eval(nil))
+	require.Equal(t, U, (&NotExpr{truthAst(U)}).Eval(nil))
+	require.False(t, (&NotExpr{truthAst(U)}).Match(nil))
+}
+
 func TestOr(t *testing.T) {
 	cases := []struct{ left, right, expected bool }{
 		{false, false, false},
@@ -23,7 +63,7 @@ func TestOr(t *testing.T) {
 	}
 
 	for _, cas := range cases {
-		require.Equal(t, cas.expected, (&exprOr{constAst(cas.left), constAst(cas.right)}).Match(nil))
+		require.Equal(t, cas.expected, (&OrExpr{constAst(cas.left), constAst(cas.right)}).Match(nil))
 	}
 }
 
@@ -36,78 +76,111 @@ func TestAnd(t *testing.T) {
 	}
 
 	for _, cas := range cases {
-		require.Equal(t, cas.expected, (&exprAnd{constAst(cas.left), constAst(cas.right)}).Match(nil))
+		require.Equal(t, cas.expected, (&AndExpr{constAst(cas.left), constAst(cas.right)}).Match(nil))
 	}
 }
 
 func TestNot(t *testing.T) {
-	require.Equal(t, true, (&exprNot{constAst(false)}).Match(nil))
-	require.Equal(t, false, (&exprNot{constAst(true)}).Match(nil))
+	require.Equal(t, true, (&NotExpr{constAst(false)}).Match(nil))
+	require.Equal(t, false, (&NotExpr{constAst(true)}).Match(nil))
+}
+
+func TestCompNative(t *testing.T) {
+	cases := []struct {
+		q        Queryable
+		comp     *CompExpr
+		expected bool
+	}{
+		{
+			q:        &mockQueryable{t: t, field: "x", operator: LT, value: "1", result: true},
+			comp:     &CompExpr{Field: "x", Operator: "<", Value: "1"},
+			expected: true,
+		},
+		{
+			q:        &mockQueryable{t: t, field: "x", operator: LE, value: "1", result: false},
+			comp:     &CompExpr{Field: "x", Operator: "<=", Value: "1"},
+			expected: false,
+		},
+		{
+			q:        &mockQueryable{t: t, field: "x", operator: GE, value: "1", result: true},
+			comp:     &CompExpr{Field: "x", Operator: ">=", Value: "1"},
+			expected: true,
+		},
+		{
+			q:        &mockQueryable{t: t, field: "x", operator: NE, value: "1", result: false},
+			comp:     &CompExpr{Field: "x", Operator: "!=", Value: "1"},
+			expected: false,
+		},
+	}
+
+	for _, cas := range cases {
+		require.Equal(t, cas.expected, cas.comp.Match(cas.q), "%v on %v", cas.comp, cas.q)
+	}
 }
 
 func TestComp(t *testing.T) {
 	cases := []struct {
 		q        Queryable
-		comp     *exprComp
+		comp     *CompExpr
 		expected bool
 	}{
 		// bool
 		{
 			q:        &mockQueryable{t: t, field: "x", operator: IS, result: true},
-			comp:     &exprComp{field: "x"},
+			comp:     &CompExpr{Field: "x"},
 			expected: true,
 		},
 		{
 			q:        &mockQueryable{t: t, field: "x", operator: IS, result: false},
-			comp:     &exprComp{field: "x"},
+			comp:     &CompExpr{Field: "x"},
 			expected: false,
 		},
 
 		//str, =
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: EQ, value: "jawher/query", result: true},
-			comp:     &exprComp{field: "field", operator: "=", value: "jawher/query"},
+			comp:     &CompExpr{Field: "field", Operator: "=", Value: "jawher/query"},
 			expected: true,
 		},
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: EQ, value: "jaer/query", result: false},
-			comp:     &exprComp{field: "field", operator: "=", value: "jaer/query"},
+			comp:     &CompExpr{Field: "field", Operator: "=", Value: "jaer/query"},
 			expected: false,
 		},
 
 		//str, !=
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: EQ, value: "jawher/query", result: true},
-			comp:     &exprComp{field: "field", operator: "!=", value: "jawher/query"},
+			comp:     &CompExpr{Field: "field", Operator: "!=", Value: "jawher/query", Derived: true},
 			expected: false,
 		},
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: EQ, value: "jaer/query", result: false},
-			comp:     &exprComp{field: "field", operator: "!=", value: "jaer/query"},
+			comp:     &CompExpr{Field: "field", Operator: "!=", Value: "jaer/query", Derived: true},
 			expected: true,
 		},
 
 		//str, ~
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: LIKE, value: "quer", result: true},
-			comp:     &exprComp{field: "field", operator: "~", value: "quer"},
+			comp:     &CompExpr{Field: "field", Operator: "~", Value: "quer"},
 			expected: true,
 		},
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: LIKE, value: "bateau", result: false},
-			comp:     &exprComp{field: "field", operator: "~", value: "bateau"},
+			comp:     &CompExpr{Field: "field", Operator: "~", Value: "bateau"},
 			expected: false,
 		},
 
 		//str, !~
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: LIKE, value: "quer", result: true},
-			comp:     &exprComp{field: "field", operator: "!~", value: "quer"},
+			comp:     &CompExpr{Field: "field", Operator: "!~", Value: "quer"},
 			expected: false,
 		},
 		{
 			q:        &mockQueryable{t: t, field: "field", operator: LIKE, value: "bateau", result: false},
-			comp:     &exprComp{field: "field", operator: "!~", value: "bateau"},
+			comp:     &CompExpr{Field: "field", Operator: "!~", Value: "bateau"},
 			expected: true,
 		},
 
@@ -120,7 +193,7 @@ func TestComp(t *testing.T) {
 				value:    "42",
 				result:   true,
 			},
-			comp:     &exprComp{field: "field", operator: ">", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: ">", Value: "42"},
 			expected: true,
 		},
 		{
@@ -131,7 +204,7 @@ func TestComp(t *testing.T) {
 				value:    "42",
 				result:   false,
 			},
-			comp:     &exprComp{field: "field", operator: ">", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: ">", Value: "42"},
 			expected: false,
 		},
 
@@ -144,7 +217,7 @@ func TestComp(t *testing.T) {
 				value:    "42",
 				result:   true,
 			},
-			comp:     &exprComp{field: "field", operator: ">=", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: ">=", Value: "42", Derived: true},
 			expected: true,
 		},
 		{
@@ -156,7 +229,7 @@ func TestComp(t *testing.T) {
 				result:   false,
 				then:     &mockQueryable{operator: EQ, result: true},
 			},
-			comp:     &exprComp{field: "field", operator: ">=", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: ">=", Value: "42", Derived: true},
 			expected: true,
 		},
 
@@ -169,7 +242,7 @@ func TestComp(t *testing.T) {
 				value:    "42",
 				result:   true,
 			},
-			comp:     &exprComp{field: "field", operator: "<", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: "<", Value: "42", Derived: true},
 			expected: false,
 		},
 		{
@@ -181,7 +254,7 @@ func TestComp(t *testing.T) {
 				result:   false,
 				then:     &mockQueryable{operator: EQ, result: true},
 			},
-			comp:     &exprComp{field: "field", operator: "<", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: "<", Value: "42", Derived: true},
 			expected: false,
 		},
 
@@ -194,7 +267,7 @@ func TestComp(t *testing.T) {
 				value:    "42",
 				result:   true,
 			},
-			comp:     &exprComp{field: "field", operator: "<=", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: "<=", Value: "42", Derived: true},
 			expected: false,
 		},
 		{
@@ -205,7 +278,7 @@ func TestComp(t *testing.T) {
 				value:    "42",
 				result:   false,
 			},
-			comp:     &exprComp{field: "field", operator: "<=", value: "42"},
+			comp:     &CompExpr{Field: "field", Operator: "<=", Value: "42", Derived: true},
 			expected: true,
 		},
 	}
@@ -245,3 +318,131 @@ func (c *mockQueryable) Is(field string, operator Operator, value string) bool {
 func (c *mockQueryable) String() string {
 	return fmt.Sprintf("%s %v %s => %v", c.field, c.operator, c.value, c.result)
 }
+
+type sliceQueryable map[string][]string
+
+func (s sliceQueryable) Is(field string, operator Operator, value string) bool {
+	panic("the elements should be queried instead")
+}
+
+func (s sliceQueryable) Elements(field string) []Queryable {
+	var res []Queryable
+	for _, v := range s[field] {
+		res = append(res, eqQueryable{field: field, value: v})
+	}
+	return res
+}
+
+type eqQueryable struct{ field, value string }
+
+func (e eqQueryable) Is(field string, operator Operator, value string) bool {
+	return field == e.field && operator == EQ && value == e.value
+}
+
+func TestQuant(t *testing.T) {
+	q := sliceQueryable{
+		"none": {},
+		"one":  {"a"},
+		"many": {"a", "b", "c"},
+	}
+
+	cases := []struct {
+		quantifier, field string
+		expected          bool
+	}{
+		{quantAny, "none", false},
+		{quantAll, "none", true},
+		{quantNone, "none", true},
+
+		{quantAny, "one", true},
+		{quantAll, "one", true},
+		{quantNone, "one", false},
+
+		{quantAny, "many", true},
+		{quantAll, "many", false},
+		{quantNone, "many", false},
+	}
+
+	for _, cas := range cases {
+		quant := &QuantExpr{
+			Quantifier: cas.quantifier,
+			Field:      cas.field,
+			Expression: &CompExpr{Field: cas.field, Operator: "=", Value: "a"},
+		}
+		require.Equal(t, cas.expected, quant.Match(q), "%s(%s=a)", cas.quantifier, cas.field)
+	}
+
+	// queryables without slice fields behave as a single element
+	all := &QuantExpr{Quantifier: quantAll, Field: "field", Expression: &CompExpr{Field: "field", Operator: "=", Value: "a"}}
+	require.True(t, all.Match(eqQueryable{field: "field", value: "a"}))
+	require.False(t, all.Match(eqQueryable{field: "field", value: "b"}))
+}
+
+// fieldsQueryable is a ValueQueryable whose fields are strings, only supporting EQ
+type fieldsQueryable map[string]string
+
+func (f fieldsQueryable) Is(field string, operator Operator, value string) bool {
+	return operator == EQ && f[field] == value
+}
+
+func (f fieldsQueryable) Value(field string) (interface{}, bool) {
+	value, found := f[field]
+	return value, found
+}
+
+func TestUnknown(t *testing.T) {
+	q := fieldsQueryable{"name": "web"}
+
+	require.Equal(t, True, (&CompExpr{Field: "name", Operator: "=", Value: "web"}).Eval(q))
+	require.Equal(t, False, (&CompExpr{Field: "name", Operator: "!=", Value: "web"}).Eval(q))
+	require.Equal(t, Unknown, (&CompExpr{Field: "exit", Operator: "=", Value: "0"}).Eval(q))
+	require.Equal(t, Unknown, (&CompExpr{Field: "exit", Operator: "!=", Value: "0"}).Eval(q))
+	// testing the field existence is never Unknown
+	require.Equal(t, False, (&CompExpr{Field: "exit"}).Eval(q))
+
+	require.Equal(t, False, (&NullExpr{Field: "name"}).Eval(q))
+	require.Equal(t, True, (&NullExpr{Field: "name", Negated: true}).Eval(q))
+	require.Equal(t, True, (&NullExpr{Field: "exit"}).Eval(q))
+	require.Equal(t, False, (&NullExpr{Field: "exit", Negated: true}).Eval(q))
+
+	// without values, the fields are tested with IS
+	require.Equal(t, False, (&NullExpr{Field: "running"}).Eval(&mockQueryable{t: t, field: "running", operator: IS, result: true}))
+	require.Equal(t, True, (&NullExpr{Field: "running"}).Eval(&mockQueryable{t: t, field: "running", operator: IS, result: false}))
+	require.Equal(t, False, (&NullExpr{Field: "running", Negated: true}).Eval(&mockQueryable{t: t, field: "running", operator: IS, result: false}))
+}
+
+func TestQuantUnknown(t *testing.T) {
+	q := sliceQueryable{"many": {"a", "b"}}
+	cases := []struct {
+		quantifier string
+		elements   []Truth
+		expected   Truth
+	}{
+		{quantAny, []Truth{False, Unknown}, Unknown},
+		{quantAny, []Truth{Unknown, True}, True},
+		{quantAll, []Truth{True, Unknown}, Unknown},
+		{quantAll, []Truth{Unknown, False}, False},
+		{quantNone, []Truth{False, Unknown}, Unknown},
+		{quantNone, []Truth{Unknown, True}, False},
+	}
+
+	for _, cas := range cases {
+		quant := &QuantExpr{
+			Quantifier: cas.quantifier,
+			Field:      "many",
+			Expression: elementsAst{"a": cas.elements[0], "b": cas.elements[1]},
+		}
+		require.Equal(t, cas.expected, quant.Eval(q), "%s(%v)", cas.quantifier, cas.elements)
+	}
+}
+
+// elementsAst evaluates to a truth value depending on the value of the eqQueryable it is applied to
+type elementsAst map[string]Truth
+
+func (e elementsAst) Match(queryable Queryable) bool {
+	return e.Eval(queryable) == True
+}
+
+func (e elementsAst) Eval(queryable Queryable) Truth {
+	return e[queryable.(eqQueryable).value]
+}
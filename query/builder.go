@@ -0,0 +1,137 @@
+package query
+
+// FieldBuilder builds the conditions on a field, e.g. Field("created").Gt("2w")
+type FieldBuilder struct {
+	name string
+}
+
+/*
+Field starts a condition on a field, whose values are used as is, without the quoting rules of the query strings,
+e.g. Field("name").Eq(`a "b" | c`) matches the same objects as name="a \"b\" | c".
+*/
+func Field(name string) FieldBuilder {
+	return FieldBuilder{name: name}
+}
+
+// Is tests a boolean field or the existence of a field, like in "running"
+func (f FieldBuilder) Is() Expression {
+	return &CompExpr{Field: f.name}
+}
+
+// Eq builds a field=value condition
+func (f FieldBuilder) Eq(value string) Expression {
+	return f.compare(EQ, value)
+}
+
+// Ne builds a field!=value condition
+func (f FieldBuilder) Ne(value string) Expression {
+	return f.compare(NE, value)
+}
+
+// Like builds a field~value condition
+func (f FieldBuilder) Like(value string) Expression {
+	return f.compare(LIKE, value)
+}
+
+// NotLike builds a field!~value condition
+func (f FieldBuilder) NotLike(value string) Expression {
+	return f.compare("!~", value)
+}
+
+// Gt builds a field>value condition
+func (f FieldBuilder) Gt(value string) Expression {
+	return f.compare(GT, value)
+}
+
+// Ge builds a field>=value condition
+func (f FieldBuilder) Ge(value string) Expression {
+	return f.compare(GE, value)
+}
+
+// Lt builds a field<value condition
+func (f FieldBuilder) Lt(value string) Expression {
+	return f.compare(LT, value)
+}
+
+// Le builds a field<=value condition
+func (f FieldBuilder) Le(value string) Expression {
+	return f.compare(LE, value)
+}
+
+// IsNull builds a "field is null" condition
+func (f FieldBuilder) IsNull() Expression {
+	return &NullExpr{Field: f.name}
+}
+
+// IsNotNull builds a "field is not null" condition
+func (f FieldBuilder) IsNotNull() Expression {
+	return &NullExpr{Field: f.name, Negated: true}
+}
+
+func (f FieldBuilder) compare(operator Operator, value string) Expression {
+	return &CompExpr{Field: f.name, Operator: string(operator), Value: value}
+}
+
+// And matches if all the expressions match, grouped from the left like "a & b & c" is
+func And(first Expression, others ...Expression) Expression {
+	res := first
+	for _, other := range others {
+		res = &AndExpr{Left: res, Right: other}
+	}
+	return res
+}
+
+// Or matches if any of the expressions matches, grouped from the left like "a | b | c" is
+func Or(first Expression, others ...Expression) Expression {
+	res := first
+	for _, other := range others {
+		res = &OrExpr{Left: res, Right: other}
+	}
+	return res
+}
+
+// Not matches if the expression does not, like "!expr"
+func Not(expr Expression) Expression {
+	return &NotExpr{Expression: expr}
+}
+
+// Any matches if any of the values of the multi-valued field referenced by the expression matches, like "any(expr)"
+func Any(expr Expression) Expression {
+	return quantify(quantAny, expr)
+}
+
+// All matches if all the values of the multi-valued field referenced by the expression match, like "all(expr)"
+func All(expr Expression) Expression {
+	return quantify(quantAll, expr)
+}
+
+// None matches if none of the values of the multi-valued field referenced by the expression matches, like "none(expr)"
+func None(expr Expression) Expression {
+	return quantify(quantNone, expr)
+}
+
+func quantify(quantifier string, expr Expression) Expression {
+	res := &QuantExpr{Quantifier: quantifier, Expression: expr}
+	fields := map[string]bool{}
+	if quantifiedFields(expr, fields) && len(fields) == 1 {
+		for field := range fields {
+			res.Field = field
+		}
+	}
+	return res
+}
+
+/*
+Build validates an expression made with the builder functions against the fields, like Parse would do for the same query,
+and returns the expression Parse returns, e.g.
+
+	ast, err := query.Build(query.And(query.Field("running").Is(), query.Field("created").Gt("2w")), fields)
+
+is equivalent to query.Parse("running & created>2w", fields).
+The expression is validated node by node, like ParseJSON does: the field names must be written as in a query,
+e.g. Field("running #") is rejected, while any value can be used, even one which cannot be quoted in a query.
+The validation failures are returned as a ParseError without input.
+*/
+func Build(expr Expression, fields map[string][]Operator, options ...Option) (Expression, error) {
+	return validate(expr, fields, options...)
+}
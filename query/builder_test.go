@@ -0,0 +1,85 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	cases := []struct {
+		built Expression
+		query string
+	}{
+		{
+			built: And(Field("running").Is(), Field("created").Gt("2w")),
+			query: "running & created>2w",
+		},
+		{
+			built: Or(Field("name").Eq("a b"), Field("name").Like("x|y"), Field("name").NotLike(`say "hi"`)),
+			query: `name="a b" | name~"x|y" | name!~"say \"hi\""`,
+		},
+		{
+			built: And(Not(Or(Field("exit").Ne("0"), Field("exit").Le("1"))), Field("size").Lt("2MB"), Field("size").Ge("1MB")),
+			query: "!(exit!=0 | exit<=1) & size<2MB & size>=1MB",
+		},
+		{
+			built: Or(Field("exit").IsNull(), All(Or(Field("tag").Like("registry.local"), Field("tag").Eq("and")))),
+			query: `exit is null | all(tag~registry.local | tag="and")`,
+		},
+		{
+			built: And(None(Field("tag").IsNotNull()), Any(Not(Field("tag").Eq("")))),
+			query: `none(tag is not null) & any(!tag="")`,
+		},
+		{
+			built: Field("label.env").Eq("$ENV"),
+			query: `label.env="$ENV"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			expected, err := Parse(c.query, translateFields, Types(translateTypes))
+			require.NoError(t, err)
+			built, err := Build(c.built, translateFields, Types(translateTypes), Variables(map[string]string{"ENV": "prod"}))
+			require.NoError(t, err)
+			require.Equal(t, expected, built)
+		})
+	}
+}
+
+func TestBuilderValidation(t *testing.T) {
+	_, err := Build(Field("image").Eq("x"), translateFields)
+	require.Error(t, err)
+
+	_, err = Build(Field("name").Gt("x"), translateFields)
+	require.Error(t, err)
+
+	_, err = Build(All(And(Field("tag").Eq("x"), Field("name").Eq("y"))), translateFields)
+	require.Error(t, err)
+
+	// the field names are not parsed as query text
+	_, err = Build(And(Field("running #").Is(), Field("name").Eq("x")), translateFields)
+	require.Equal(t, ParseError{Message: `Invalid field name "running #"`}, err)
+
+	_, err = Build(Field("name=x | running").Is(), translateFields)
+	require.Equal(t, ParseError{Message: `Invalid field name "name=x | running"`}, err)
+
+	_, err = Build(Not(nil), translateFields)
+	require.EqualError(t, err, "Parse error: missing expression")
+
+	// the runtime errors are not validation failures
+	require.Panics(t, func() { _, _ = Build(Not((*CompExpr)(nil)), translateFields) })
+}
+
+func TestBuilderMatch(t *testing.T) {
+	built, err := Build(And(Field("msg").Eq(`disk "data" | full`), Field("level").Eq("error")), MapFields)
+	require.NoError(t, err)
+	require.True(t, built.Match(FromMap(map[string]interface{}{"msg": `disk "data" | full`, "level": "error"})))
+	require.False(t, built.Match(FromMap(map[string]interface{}{"msg": "disk", "level": "error"})))
+
+	// values which cannot be quoted in a query
+	built, err = Build(Field("path").Eq(`a b\`), MapFields)
+	require.NoError(t, err)
+	require.True(t, built.Match(FromMap(map[string]interface{}{"path": `a b\`})))
+}
@@ -0,0 +1,84 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+Completion describes what can follow a partial query, e.g. a query being typed on a command line:
+the word being typed at the end of the query, and what it can be completed with.
+*/
+type Completion struct {
+	// Prefix is the word being typed at the end of the query, e.g. "na" for "running & na", which the completions replace
+	Prefix string
+	// Fields is true if a field is expected, e.g. after "running & "
+	Fields bool
+	// Field is the field whose operator or value is expected, e.g. name after "name" or "name~"
+	Field string
+	// Operators are the operators Field supports, if an operator is expected
+	Operators []string
+	// Value is true if the value of a comparison is expected, e.g. after "name~"
+	Value bool
+	// Operator is the operator of the comparison whose value is expected
+	Operator string
+	// Tokens are the other tokens which can follow, e.g. "&", "|" or ")"
+	Tokens []string
+}
+
+/*
+Complete parses a partial query and returns what can follow it, e.g. that the field names starting with "na"
+can complete "running & na", or that the operators of the name field can complete "name ".
+The errors of the partial query are ignored.
+*/
+func Complete(input string, fields map[string][]Operator, options ...Option) Completion {
+	res := Completion{Prefix: completedWord(input)}
+	p := &parser{
+		lexer:      newLexer(strings.TrimSuffix(input, res.Prefix)),
+		fields:     fields,
+		completion: &res,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	_, _ = p.parse()
+	return res
+}
+
+// completedWord returns the word being typed at the end of a query, made of the characters allowed in unquoted literals
+func completedWord(input string) string {
+	start := strings.LastIndexFunc(input, func(r rune) bool {
+		return !notIn(r, notOkInLiteral) || unicode.IsSpace(r) || strings.ContainsRune(`,[]"`, r)
+	})
+	return input[start+1:]
+}
+
+// completionTokens are the tokens reported in Completion.Tokens when they are expected
+var completionTokens = map[tokenClass]bool{
+	tkNot:      true,
+	tkLparen:   true,
+	tkRparen:   true,
+	tkAnd:      true,
+	tkOr:       true,
+	tkComma:    true,
+	tkRbracket: true,
+}
+
+// expect records that a token was expected at the end of the partial query
+func (c *Completion) expect(p *parser, class tokenClass) {
+	switch {
+	case class == tkCompOp:
+		if operators, found := p.fieldOperators(p.matched.value); found && p.matched.class == tkLiteral {
+			c.Field, c.Operators = p.matched.value, operatorList(operators)
+		}
+	case (class == tkLiteral || class == tkRef) && p.left != nil:
+		c.Value, c.Field, c.Operator = true, formatOperand(p.left), p.operator
+	case completionTokens[class]:
+		for _, token := range c.Tokens {
+			if token == string(class) {
+				return
+			}
+		}
+		c.Tokens = append(c.Tokens, string(class))
+	}
+}
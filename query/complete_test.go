@@ -0,0 +1,35 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplete(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected Completion
+	}{
+		{"", Completion{Fields: true, Tokens: []string{"!", "("}}},
+		{"running & na", Completion{Prefix: "na", Fields: true, Tokens: []string{"!", "("}}},
+		{"!(", Completion{Fields: true, Tokens: []string{"!", "("}}},
+		{"@sta", Completion{Prefix: "@sta", Fields: true, Tokens: []string{"!", "("}}},
+		{"nmae=x & la", Completion{Prefix: "la", Fields: true, Tokens: []string{"!", "("}}},
+		// name cannot be used without an operator
+		{"name ", Completion{Field: "name", Operators: []string{"=", "!=", "~", "!~"}}},
+		{"running ", Completion{Field: "running", Tokens: []string{"&", "|"}}},
+		{"name~we", Completion{Prefix: "we", Field: "name", Value: true, Operator: "~"}},
+		{"label.env=", Completion{Field: "label.env", Value: true, Operator: "="}},
+		{"(running | exit>", Completion{Field: "exit", Value: true, Operator: ">"}},
+		{"exit>$cre", Completion{Prefix: "$cre", Field: "exit", Value: true, Operator: ">"}},
+		{"all(tag~x ", Completion{Tokens: []string{"&", "|", ")"}}},
+		{"(running | exit>0 ", Completion{Tokens: []string{"&", "|", ")"}}},
+		{"len(name)>", Completion{Field: "len(name)", Value: true, Operator: ">"}},
+		{`name="a b`, Completion{Prefix: "b"}},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expected, Complete(c.input, translateFields, Types(translateTypes)), "completing '%s'", c.input)
+	}
+}
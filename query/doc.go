@@ -51,6 +51,102 @@ The same operators as for string fields (=, !=, ~, !~) are available for multi-v
 - "!~" : Fails if any value of the slice contains the provided value
   cmd!~sh
 
+Ordered fields
+
+Numbers, sizes and durations can be compared with the "=", "!=", ">", ">=", "<" and "<=" operators:
+
+  exit>=128
+
+Queryables are asked for the NE, LT, GE and LE operators only for the fields declaring them in the fields map,
+e.g. {EQ, NE, GT, GE, LT, LE}.
+For the fields declaring only EQ and GT, "!=", ">=", "<" and "<=" are derived from them, e.g. exit<1 is !exit>1 & !exit=1,
+which also matches when the field has no value on queryables not implementing ValueQueryable.
+Parse marks these comparisons with CompExpr.Derived, while the comparisons built without it ask the queryable for the operator.
+
+Quantifiers
+
+The any, all and none quantifiers apply a condition to every element of a slice field, for queryables implementing SliceQueryable,
+and match if respectively at least one, every or no element matches it:
+
+  all(tag~registry.local)
+
+  none(cmd=/bin/sh)
+
+  any(tag~latest | tag~stable)
+
+The quantified condition can only reference a single field.
+
+Functions
+
+Functions can be applied to a field on the left side of a comparison, for queryables implementing ValueQueryable:
+
+  len(name) > 40
+
+  count(network) >= 2
+
+  lower(label.env) = prod
+
+  split(image, ":")[-1] = latest
+
+The builtin functions are:
+
+- len: the number of values of a slice field or the number of characters of a string field
+
+- count: the number of values of a slice field
+
+- lower, upper, trim: the string in lower case, in upper case or without its surrounding spaces
+
+- basename: the last element of a path
+
+- split: the slice of the parts of a string around a separator
+
+Functions can be nested, and the slices they return can be indexed, from the end for negative indexes.
+A function which cannot be applied to a value, e.g. a missing field, is Unknown.
+When the field types are provided with the Types option, applying a function to a field of another type is a parse error.
+
+More functions can be registered with RegisterFunction.
+
+Field comparisons
+
+For queryables implementing ValueQueryable, a field, or a value computed by functions, can be compared to another field
+referenced with "$":
+
+  label.version != $image.label.version
+
+  exited < $created
+
+When the field types are provided with the Types option, comparing fields of incompatible types is a parse error.
+Strings and slices can be compared, a slice matching if any of its values matches.
+A comparison where either value is missing is Unknown.
+Literal values starting with "$" must be quoted.
+
+Variables
+
+Queries parsed with the Variables option can reference variables with "$name".
+The reference is replaced by a literal holding the variable value, so that values containing operators,
+e.g. "a | b", cannot change the query structure:
+
+  name = $APP & created > $MAX_AGE
+
+Variables take precedence over fields, and the Environment option provides fallback values for the references
+which are neither variables nor fields.
+
+Arithmetic
+
+The value of a comparison can be computed from integers, durations, sizes and $field references with the "+", "-", "*"
+and "/" operators, which must be surrounded by spaces:
+
+  exited > $created - 1h
+
+  size > 2 * 500MB
+
+Literals are parsed with strconv.ParseInt, ParseDuration and ParseSize, in that order, and the other ones naming a field
+are references to it: exited > created - 1h is the same as exited > $created - 1h.
+Durations and sizes can be multiplied or divided by integers, sizes can be added to integers,
+and time values are handled as their age, like in created>2w.
+Operations between incompatible types are parse errors, and so are unspaced operators in the values of int, duration
+and size fields, e.g. size>2*500MB.
+
 Negation
 
 A condition can be negated using the "!" operator, e.g.:
@@ -61,6 +157,10 @@ or
 
   !name=server
 
+The "not" keyword can be used instead of "!":
+
+  not running
+
 And
 
 Two or more conditions can be combined using the boolean and operator "&"
@@ -71,6 +171,10 @@ or
 
   running & image=mongo & cmd~sh
 
+The "and" keyword can be used instead of "&":
+
+  running and name=server
+
 Or
 
 Two or more conditions can be combined using the boolean or operator "|"
@@ -81,6 +185,27 @@ or
 
   running | image=mongo | cmd~sh
 
+The "or" keyword can be used instead of "|":
+
+  running or name=server
+
+The "and", "or" and "not" keywords are case insensitive, and must be quoted to be used as values:
+
+  name="or"
+
+Missing values
+
+For queryables implementing ValueQueryable, a condition on a field without value, e.g. exit=0 on a container which
+never ran, evaluates to Unknown, and so does its negation.
+Unknown conditions combine with "&" and "|" using three-valued logic, as in SQL, and only True expressions match.
+Testing a field without an operator, e.g. label.arch, is False when the field has no value.
+
+The presence of a value can be tested explicitly with "is null" and "is not null":
+
+  exit is null | exit != 0
+
+For the other queryables, a field is null when Is(field, IS, "") is false.
+
 Parenthesis
 
 To control the evaluation precedence, conditions can be wrapped between "(" and ")":
@@ -88,15 +213,131 @@ To control the evaluation precedence, conditions can be wrapped between "(" and
   running & !(name=server | image~mongo)
 
 
+Named queries
+
+Queries passed to Parse with the NamedQueries option can be referenced by name with "@name".
+The reference is replaced by the named query text, wrapped in parenthesis, before being parsed:
+
+  @stale & label.team=infra
+
+Error positions refer to the expanded query text.
+
+Comments
+
+Queries can span several lines, and a "#" at the beginning of a word starts a comment running to the end of the line:
+
+  created > 2w   # old enough
+  & !running
+
+Parse errors in multi-line queries show the line of the error.
+
+Go structs
+
+Instead of implementing Queryable, structs can be queried with FromStruct, using the fields and types returned by StructSchema:
+
+  type Container struct {
+    Name   string
+    Labels map[string]string `query:"label"`
+    State  struct {
+      Running  bool
+      ExitCode int
+    }
+  }
+
+  fields, types := query.StructSchema(Container{})
+  matcher, err := query.Parse("label.env=prod & !state.running", fields, query.Types(types))
+  if matcher.Match(query.FromStruct(container)) {
+    :
+  }
+
+Maps and JSON documents
+
+Decoded JSON documents, or any map of maps, slices and scalars, can be queried with FromMap, and raw JSON objects with FromJSON,
+using MapFields, which accepts any field:
+
+  q, err := query.FromJSON([]byte(`{"user": {"name": "alice"}, "items": [{"price": 12}, {"price": 3}]}`))
+  matcher, err := query.Parse("user.name=alice & items.price>10", query.MapFields)
+
+Fields are dotted paths, and the paths going through arrays match if any of the values match.
+
+Inspecting queries
+
+The parsed queries are made of exported node types, e.g. AndExpr or CompExpr, which can be traversed with Walk or Inspect.
+Fields lists the fields used by a query, and Rewrite transforms it:
+
+  ast, err := query.Parse("running & label.team=infra", fields)
+  query.Fields(ast) // [label.team running]
+
+Translating queries
+
+ToSQL translates a query to a parameterized PostgreSQL WHERE clause, given the columns of the fields,
+and ToMongo to a MongoDB filter document, given the paths of the fields:
+
+  where, args, err := query.ToSQL(ast, map[string]string{"name": "name", "label.*": "labels->>%s"}, types)
+  rows, err := db.Query("SELECT id FROM containers WHERE "+where, args...)
+
+The constructs which cannot be translated, e.g. function calls, are reported with a TranslateError.
+
+Formatting queries
+
+Format prints a query in its canonical form, which parses back to the same query, and is also what the String methods of the nodes return:
+
+  ast, err := query.Parse(`( running and name ~ web ) or label.env = "prod env"`, fields)
+  query.Format(ast) // running & name~web | label.env="prod env"
+
+The comments are dropped and the named queries expanded when parsing: Annotated tells whether formatting loses them.
+
+Building queries
+
+Queries can be built with Go functions instead of formatting query strings, whose quoting rules are easy to get wrong,
+and validated with Build, which returns the same expression as Parse:
+
+  ast, err := query.Build(query.And(query.Field("running").Is(), query.Field("name").Eq("a b | c")), fields)
+
+Storing queries as JSON
+
+The expressions can be encoded with json.Marshal, e.g. {"type": "comparison", "field": "name", "operator": "~", "value": "web"},
+to store them or send them to another service. ParseJSON decodes them and validates them against the fields like Parse would do,
+node by node, so that names containing query syntax, e.g. a "running #" field, are rejected. UnmarshalExpression only decodes them:
+
+  data, err := json.Marshal(ast)
+  ast, err = query.ParseJSON(data, fields, query.Types(types))
+
+Errors
+
+Parse returns a ParseError pointing at the offending input. Its message suggests the closest field or function for unknown ones,
+and lists the operators supported by a field when another one is used. For syntax errors, Expected describes the accepted tokens.
+The values compared to the int, duration and size fields are checked too, e.g. exit>abc or exit!="" are rejected.
+The parsing resumes after an invalid condition, at the next "&", "|" or closing parenthesis, and a query with several errors
+returns them all as ParseErrors:
+
+  _, err := query.Parse("running & (nmae=x", fields) // Unknown field nmae, did you mean name?
+  _, err = query.Parse("running x", fields)          // Unexpected "x", was expecting an operator, "&", "|" or the end of the query
+
+Completing queries
+
+Complete parses a partial query, e.g. the one typed on a command line, and tells what can follow it:
+the word being typed, and whether it is a field, an operator of a given field, the value compared to a field, or other tokens:
+
+  c := query.Complete("running & name~we", fields) // Prefix: "we", Field: "name", Operator: "~", Value: true
+
 Grammar
 
 The query langauge is described below using the EBNF notation:
 
   expr     -> or
-  or       -> and ('|' and)*
-  and      -> atom ('&' atom)*
-  atom     -> cond | '(' expr ')' | '!' atom
-  cond     -> LITERAL (OPERATOR LITERAL)?
+  or       -> and (('|' | 'or') and)*
+  and      -> atom (('&' | 'and') atom)*
+  atom     -> cond | call | '(' expr ')' | ('!' | 'not') atom | '@' NAME | QUANT '(' expr ')'
+  QUANT    -> 'any' | 'all' | 'none'
+  call     -> value (OPERATOR rhs)?
+  value    -> FUNC '(' arg (',' LITERAL)* ')' ('[' INDEX ']')*
+  arg      -> value | LITERAL
+  FUNC     -> 'len' | 'count' | 'lower' | 'upper' | 'trim' | 'basename' | 'split' | registered functions
+  cond     -> LITERAL (OPERATOR rhs)? | LITERAL 'is' 'not'? 'null'
+  rhs      -> product (('+' | '-') product)*
+  product  -> operand (('*' | '/') operand)*
+  operand  -> LITERAL | '$' LITERAL
   LITERAL  -> "[^|&!=~]+"
   OPERATOR -> '=' | '!=' | '~' | '!~' | '<' | '<=' | '>' | '>='
 */
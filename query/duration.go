@@ -1,4 +1,4 @@
-package main
+package query
 
 import (
 	"fmt"
@@ -7,12 +7,16 @@ import (
 	"time"
 )
 
-func parseDuration(input string) (time.Duration, error) {
-	return (&durationParser{&parser{input: input}}).parse()
+/*
+ParseDuration parses a duration made of one or more numbers followed by a unit, e.g. "2w", "1d 12h".
+The supported units are ms, s, m, h, d, w, M (or months) and y.
+*/
+func ParseDuration(input string) (time.Duration, error) {
+	return (&durationParser{&unitParser{input: input}}).parse()
 }
 
 type durationParser struct {
-	*parser
+	*unitParser
 }
 
 func (p *durationParser) parse() (res time.Duration, err error) {
@@ -86,3 +90,17 @@ func (p *durationParser) parseUnit() int64 {
 	}
 	panic("was expecting a duration unit")
 }
+
+var durationFormatUnits = []string{"y", "M", "w", "d", "h", "m", "s", "ms"}
+
+/*
+FormatDuration renders a duration using its two most significant units, e.g. "2w 3d".
+The result is a valid input for ParseDuration.
+*/
+func FormatDuration(d time.Duration) string {
+	ms := d.Nanoseconds() / (1000 * 1000)
+	if ms < 1 {
+		return "0ms"
+	}
+	return formatUnits(ms, durationFormatUnits, durationUnitMultipliers)
+}
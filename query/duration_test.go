@@ -1,4 +1,4 @@
-package main
+package query
 
 import (
 	"testing"
@@ -38,7 +38,7 @@ func TestParseDuration(t *testing.T) {
 	}
 
 	for _, cas := range cases {
-		dur, err := parseDuration(cas.input)
+		dur, err := ParseDuration(cas.input)
 		if cas.ko {
 			t.Log(err)
 			require.Error(t, err, "parsing '%s' should have failed", cas.input)
@@ -48,3 +48,29 @@ func TestParseDuration(t *testing.T) {
 		require.Equal(t, cas.ok, dur, "input '%s' should parse to %v, instead got %v", cas.input, cas.ok, dur)
 	}
 }
+
+func TestFormatDuration(t *testing.T) {
+	cases := []struct {
+		input    time.Duration
+		expected string
+	}{
+		{0, "0ms"},
+		{42 * time.Millisecond, "42ms"},
+		{42 * time.Second, "42s"},
+		{90 * time.Minute, "1h 30m"},
+		{17 * 24 * time.Hour, "2w 3d"},
+		{17*24*time.Hour + 5*time.Hour, "2w 3d"},
+		{14 * 24 * time.Hour, "2w"},
+		{400 * 24 * time.Hour, "1y 1M"},
+	}
+
+	for _, cas := range cases {
+		formatted := FormatDuration(cas.input)
+		require.Equal(t, cas.expected, formatted, "duration %v should be formatted as '%s'", cas.input, cas.expected)
+
+		if cas.input > 0 {
+			_, err := ParseDuration(formatted)
+			require.NoError(t, err, "formatted duration '%s' should be parseable", formatted)
+		}
+	}
+}
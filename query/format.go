@@ -0,0 +1,132 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Format returns the canonical form of a query, which parses back to the same query:
+the boolean operators are spelled "|", "&" and "!", conditions are written without spaces, e.g. name~web,
+binary operators are surrounded by single spaces, and only the parenthesis required by the precedence of "&" over "|"
+are kept, e.g. "running & (name=a | name=b)".
+Values are quoted only when they could not be parsed otherwise, e.g. name="a b" or name="or",
+while the parameters of function calls are always quoted, e.g. split(image, ":").
+The values ending with a backslash, or with a backslash before a double quote, cannot be written in the query language,
+as \" always escapes a double quote: they are formatted as is, and do not parse back.
+*/
+func Format(expr Expression) string {
+	switch e := expr.(type) {
+	case *OrExpr:
+		return Format(e.Left) + " | " + Format(e.Right)
+	case *AndExpr:
+		return formatAndSide(e.Left) + " & " + formatAndSide(e.Right)
+	case *NotExpr:
+		switch e.Expression.(type) {
+		case *OrExpr, *AndExpr:
+			return "!(" + Format(e.Expression) + ")"
+		default:
+			return "!" + Format(e.Expression)
+		}
+	case *CompExpr:
+		if len(e.Operator) == 0 {
+			return e.Field
+		}
+		return e.Field + e.Operator + Quote(e.Value)
+	case *NullExpr:
+		if e.Negated {
+			return e.Field + " is not null"
+		}
+		return e.Field + " is null"
+	case *QuantExpr:
+		return e.Quantifier + "(" + Format(e.Expression) + ")"
+	case *ValueExpr:
+		left := formatOperand(e.Left)
+		switch {
+		case len(e.Operator) == 0:
+			return left
+		case e.Right != nil:
+			return left + e.Operator + formatOperand(e.Right)
+		default:
+			return left + e.Operator + Quote(e.Value)
+		}
+	default:
+		return fmt.Sprint(expr)
+	}
+}
+
+// formatAndSide formats a side of an and, wrapping ors in parenthesis as "&" has a higher precedence than "|"
+func formatAndSide(expr Expression) string {
+	if _, ok := expr.(*OrExpr); ok {
+		return "(" + Format(expr) + ")"
+	}
+	return Format(expr)
+}
+
+func formatOperand(op Operand) string {
+	switch o := op.(type) {
+	case *FieldOperand:
+		return o.Field
+	case *RefOperand:
+		return "$" + o.Field
+	case *CallOperand:
+		args := []string{formatOperand(o.Arg)}
+		for _, param := range o.Params {
+			args = append(args, quote(param))
+		}
+		return fmt.Sprintf("%s(%s)", o.Function, strings.Join(args, ", "))
+	case *IndexOperand:
+		return fmt.Sprintf("%s[%d]", formatOperand(o.List), o.Index)
+	case *LiteralOperand:
+		return Quote(o.Text)
+	case *ArithOperand:
+		return fmt.Sprintf("%s %s %s", formatOperand(o.Left), o.Operator, formatOperand(o.Right))
+	default:
+		return fmt.Sprint(op)
+	}
+}
+
+/*
+Annotated returns true if a query has comments or @name references to named queries, which Format does not keep
+as the comments are dropped and the references expanded when parsing, e.g. to refuse rewriting a query file.
+*/
+func Annotated(input string) (res bool) {
+	lx := newLexer(input)
+	defer func() {
+		// the lexing errors, e.g. an unknown named query, are reported by Parse
+		_ = recover()
+		res = lx.annotated
+	}()
+	for lx.next().class != tkEOF {
+	}
+	return lx.annotated
+}
+
+// Quote returns a value as it should be written in a query: quoted if the lexer would not read it as a single literal, e.g. "a b"
+func Quote(value string) string {
+	if needsQuotes(value) {
+		return quote(value)
+	}
+	return value
+}
+
+func quote(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func needsQuotes(value string) bool {
+	if len(value) == 0 || keywords[strings.ToLower(value)] != "" || arithOperators[value] {
+		return true
+	}
+	if first, _ := utf8.DecodeRuneInString(value); strings.ContainsRune(`"$@#`, first) {
+		return true
+	}
+	for _, r := range value {
+		if !notIn(r, notOkInLiteral) || unicode.IsSpace(r) || strings.ContainsRune(`,[]"`, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+		// regrouped is set when the parser groups the formatted query differently, | and & being associative
+		regrouped bool
+	}{
+		{input: "name = web", expected: "name=web"},
+		{input: "running and not paused", expected: "running & !paused"},
+		{input: "((a | b) | c)", expected: "a | b | c"},
+		{input: "a | (b | c)", expected: "a | b | c", regrouped: true},
+		{input: "(a & b) | c", expected: "a & b | c"},
+		{input: "a & (b | c)", expected: "a & (b | c)"},
+		{input: "(a | b) & !(c & d)", expected: "(a | b) & !(c & d)"},
+		{input: "!(name~web)", expected: "!name~web"},
+		{input: "NOT NOT running", expected: "!!running"},
+		{input: `name="a b" & label.env="prod"`, expected: `name="a b" & label.env=prod`},
+		{input: `name="or" | name="(x)" | name="" | name="$x" | name="-"`, expected: `name="or" | name="(x)" | name="" | name="$x" | name="-"`},
+		{input: `cmd="a,b" & cmd="say \"hi\""`, expected: `cmd="a,b" & cmd="say \"hi\""`},
+		{input: "exit   is  not null | exit IS NULL", expected: "exit is not null | exit is null"},
+		{input: "ALL( tag ~ x | tag=y )", expected: "all(tag~x | tag=y)"},
+		{input: `len( split(image,":")[ 0 ] )>3`, expected: `len(split(image, ":")[0])>3`},
+		{input: "exited >  $created   +  1h", expected: "exited>$created + 1h"},
+		{input: "a # comment\n& b", expected: "a & b"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			ast, err := Parse(c.input, MapFields)
+			require.NoError(t, err)
+			formatted := Format(ast)
+			require.Equal(t, c.expected, formatted)
+
+			reparsed, err := Parse(formatted, MapFields)
+			require.NoError(t, err)
+			if !c.regrouped {
+				require.Equal(t, ast, reparsed)
+			}
+			require.Equal(t, formatted, Format(reparsed))
+		})
+	}
+}
+
+func TestFormatString(t *testing.T) {
+	ast, err := Parse(`running & (name="a b" | exit>0)`, MapFields)
+	require.NoError(t, err)
+	require.Equal(t, `running & (name="a b" | exit>0)`, ast.(*AndExpr).String())
+	require.Equal(t, `name="a b" | exit>0`, ast.(*AndExpr).Right.(*OrExpr).String())
+}
+
+func TestAnnotated(t *testing.T) {
+	for input, expected := range map[string]bool{
+		"running & name=web":             false,
+		"running # not the paused ones":  true,
+		"# stale containers\ncreated>2w": true,
+		"@stale & name=x":                true,
+		`name="#1" & label.mail="a@b.c"`: false,
+		"name=a#b":                       false,
+		`name="unclosed`:                 false,
+	} {
+		require.Equal(t, expected, Annotated(input), input)
+	}
+}
+
+func TestFormatBackslash(t *testing.T) {
+	// a backslash before a double quote always escapes it, so that such values cannot be written in a query
+	_, err := Parse(Format(&CompExpr{Field: "name", Operator: "=", Value: `x y\`}), fields)
+	require.Error(t, err)
+}
@@ -0,0 +1,399 @@
+package query
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+ValueQueryable can be implemented by queryables to expose the values of their fields, which is required by functions like len.
+Value returns the value of a field and false if the queryable has no value for it.
+Lists are expected as []string values.
+*/
+type ValueQueryable interface {
+	Queryable
+	Value(field string) (interface{}, bool)
+}
+
+/*
+Function is a function which can be used in queries on the left side of a comparison, e.g. lower(label.env)=prod.
+A function is applied to a value, either a field or the result of another function, and to optional literal parameters,
+e.g. split(image, ":").
+*/
+type Function struct {
+	// Inputs are the types of the values the function applies to, the function applying to values of any type if there is none
+	Inputs []Type
+	// Params is the number of literal parameters following the value
+	Params int
+	// Output is the type of the values returned by the function
+	Output Type
+	// Apply computes the function result, or returns false if the function does not apply to the value, e.g. a value of another type
+	Apply func(value interface{}, params []string) (interface{}, bool)
+}
+
+/*
+RegisterFunction makes a function available to all the queries parsed afterwards, replacing any existing function with the same name.
+It is meant to be called during initialization, as the functions registry is not safe for concurrent use.
+*/
+func RegisterFunction(name string, f Function) {
+	if quantifiers[strings.ToLower(name)] {
+		panic(fmt.Sprintf("%s is a quantifier and cannot be used as a function name", name))
+	}
+	if f.Apply == nil {
+		panic(fmt.Sprintf("function %s has no Apply", name))
+	}
+	functions[name] = f
+}
+
+var functions = map[string]Function{
+	// len is the number of values of a list or the number of characters of a string
+	"len": {
+		Inputs: []Type{TypeString, TypeList},
+		Output: TypeInt,
+		Apply: func(value interface{}, _ []string) (interface{}, bool) {
+			switch v := value.(type) {
+			case []string:
+				return len(v), true
+			case string:
+				return utf8.RuneCountInString(v), true
+			default:
+				return nil, false
+			}
+		},
+	},
+	// count is the number of values of a list
+	"count": {
+		Inputs: []Type{TypeList},
+		Output: TypeInt,
+		Apply: func(value interface{}, _ []string) (interface{}, bool) {
+			v, ok := value.([]string)
+			return len(v), ok
+		},
+	},
+	"lower":    stringFunction(strings.ToLower),
+	"upper":    stringFunction(strings.ToUpper),
+	"trim":     stringFunction(strings.TrimSpace),
+	"basename": stringFunction(path.Base),
+	// split splits a string around a separator, e.g. split(image, ":")
+	"split": {
+		Inputs: []Type{TypeString},
+		Params: 1,
+		Output: TypeList,
+		Apply: func(value interface{}, params []string) (interface{}, bool) {
+			v, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			return strings.Split(v, params[0]), true
+		},
+	},
+}
+
+// accepts returns true if the function applies to the values of a type, the values of unknown types being checked when matching
+func (f Function) accepts(t Type) bool {
+	if len(f.Inputs) == 0 || t == TypeAny {
+		return true
+	}
+	for _, input := range f.Inputs {
+		if input == t {
+			return true
+		}
+	}
+	return false
+}
+
+// stringFunction returns a function transforming a string into another
+func stringFunction(transform func(string) string) Function {
+	return Function{
+		Inputs: []Type{TypeString},
+		Output: TypeString,
+		Apply: func(value interface{}, _ []string) (interface{}, bool) {
+			v, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			return transform(v), true
+		},
+	}
+}
+
+/*
+Operand is a value computed from the fields of a queryable, on either side of a ValueExpr comparison:
+a FieldOperand, RefOperand, CallOperand, IndexOperand, LiteralOperand or ArithOperand
+*/
+type Operand interface {
+	eval(queryable ValueQueryable) (interface{}, bool)
+	String() string
+}
+
+// FieldOperand is the value of a field
+type FieldOperand struct {
+	Field string
+}
+
+func (f *FieldOperand) eval(queryable ValueQueryable) (interface{}, bool) {
+	return queryable.Value(f.Field)
+}
+
+func (f *FieldOperand) String() string {
+	return formatOperand(f)
+}
+
+// RefOperand is the value of a field referenced with $field on the right side of a comparison
+type RefOperand struct {
+	FieldOperand
+}
+
+func (r *RefOperand) String() string {
+	return formatOperand(r)
+}
+
+// CallOperand is the result of a function, e.g. split(image, ":")
+type CallOperand struct {
+	Function string
+	Arg      Operand
+	Params   []string
+}
+
+func (c *CallOperand) eval(queryable ValueQueryable) (interface{}, bool) {
+	value, found := c.Arg.eval(queryable)
+	if !found {
+		return nil, false
+	}
+	return functions[c.Function].Apply(value, c.Params)
+}
+
+func (c *CallOperand) String() string {
+	return formatOperand(c)
+}
+
+// IndexOperand is an element of a list, counting from the end for negative indexes, e.g. split(image, "/")[-1]
+type IndexOperand struct {
+	List  Operand
+	Index int
+}
+
+func (i *IndexOperand) eval(queryable ValueQueryable) (interface{}, bool) {
+	value, found := i.List.eval(queryable)
+	if !found {
+		return nil, false
+	}
+	list, ok := value.([]string)
+	if !ok {
+		return nil, false
+	}
+	index := i.Index
+	if index < 0 {
+		index += len(list)
+	}
+	if index < 0 || index >= len(list) {
+		return nil, false
+	}
+	return list[index], true
+}
+
+func (i *IndexOperand) String() string {
+	return formatOperand(i)
+}
+
+/*
+ValueExpr compares a value computed by functions, e.g. len(tag)>3 or lower(label.env)=prod, either to a literal value
+or to another computed value, e.g. label.version!=$image.label.version.
+The comparison is Unknown when either value cannot be computed, e.g. from a missing field.
+*/
+type ValueExpr struct {
+	Left     Operand
+	Operator string
+	Value    string
+	Right    Operand
+}
+
+func (c *ValueExpr) String() string {
+	return Format(c)
+}
+
+func (c *ValueExpr) Match(queryable Queryable) bool {
+	return c.Eval(queryable) == True
+}
+
+func (c *ValueExpr) Eval(queryable Queryable) Truth {
+	vq, ok := queryable.(ValueQueryable)
+	if !ok {
+		panic(fmt.Sprintf("%v requires a ValueQueryable", c))
+	}
+	value, found := c.Left.eval(vq)
+	if !found {
+		return Unknown
+	}
+	if c.Right == nil {
+		return truth(compare(c.Operator, true, func(operator Operator) bool {
+			return valueIs(value, operator, c.Value)
+		}))
+	}
+	other, found := c.Right.eval(vq)
+	if !found {
+		return Unknown
+	}
+	return truth(compare(c.Operator, true, func(operator Operator) bool {
+		return valuesIs(value, operator, other)
+	}))
+}
+
+// valueIs is the equivalent of Queryable.Is for the values computed by functions
+func valueIs(value interface{}, operator Operator, pattern string) bool {
+	// a list is different from a value if none of its elements is equal to it
+	if operator == NE {
+		return !valueIs(value, EQ, pattern)
+	}
+	switch v := value.(type) {
+	case bool:
+		switch operator {
+		case IS:
+			return v
+		case EQ:
+			expected, err := strconv.ParseBool(pattern)
+			return err == nil && v == expected
+		}
+	case int:
+		expected, err := strconv.Atoi(pattern)
+		if err != nil {
+			return valueIs(float64(v), operator, pattern)
+		}
+		return order(int64(v), int64(expected), operator)
+	case float64:
+		expected, err := strconv.ParseFloat(pattern, 64)
+		if err != nil {
+			return false
+		}
+		return order(compareFloats(v, expected), 0, operator)
+	case string:
+		switch operator {
+		case EQ:
+			return v == pattern
+		case LIKE:
+			return strings.Contains(strings.ToLower(v), strings.ToLower(pattern))
+		}
+	case []string:
+		for _, element := range v {
+			if valueIs(element, operator, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+valuesIs is the equivalent of Queryable.Is between two values.
+Slices match if any of their values match, and times are compared as durations since then, like in created>2w,
+including to durations computed by arithmetic expressions.
+*/
+func valuesIs(value interface{}, operator Operator, other interface{}) bool {
+	// a list is different from another if none of their elements are equal
+	if operator == NE {
+		return !valuesIs(value, EQ, other)
+	}
+	if o, ok := other.([]string); ok {
+		for _, element := range o {
+			if valuesIs(value, operator, element) {
+				return true
+			}
+		}
+		return false
+	}
+	switch v := value.(type) {
+	case []string:
+		for _, element := range v {
+			if valuesIs(element, operator, other) {
+				return true
+			}
+		}
+		return false
+	case string:
+		o, ok := other.(string)
+		return ok && valueIs(v, operator, o)
+	case bool:
+		o, ok := other.(bool)
+		return ok && operator == EQ && v == o
+	case time.Time:
+		switch o := other.(type) {
+		case time.Time:
+			// an earlier time is older, i.e. greater
+			return order(int64(o.Sub(v)), 0, operator)
+		case time.Duration:
+			return valuesIs(now().Sub(v), operator, o)
+		}
+		return false
+	}
+
+	v, ok := toInt(value)
+	o, otherOk := toInt(other)
+	if ok && otherOk {
+		return order(v, o, operator)
+	}
+	fv, ok := toFloat(value)
+	fo, otherOk := toFloat(other)
+	return ok && otherOk && order(compareFloats(fv, fo), 0, operator)
+}
+
+// compareFloats returns -1, 0 or 1 depending on whether value is less than, equal to or greater than other
+func compareFloats(value, other float64) int64 {
+	switch {
+	case value < other:
+		return -1
+	case value > other:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// order compares two integers with the EQ, NE, GT, GE, LT or LE operator
+func order(value, other int64, operator Operator) bool {
+	switch operator {
+	case EQ:
+		return value == other
+	case NE:
+		return value != other
+	case GT:
+		return value > other
+	case GE:
+		return value >= other
+	case LT:
+		return value < other
+	case LE:
+		return value <= other
+	default:
+		return false
+	}
+}
+
+// toFloat converts the values of any integer or floating point type to float64
+func toFloat(value interface{}) (float64, bool) {
+	if i, ok := toInt(value); ok {
+		return float64(i), true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// toInt converts the values of any integer type, e.g. sizes, to int64
+func toInt(value interface{}) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,147 @@
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type valueQueryable map[string]interface{}
+
+func (v valueQueryable) Is(field string, operator Operator, value string) bool {
+	panic("the value should be used instead")
+}
+
+func (v valueQueryable) Value(field string) (interface{}, bool) {
+	value, found := v[field]
+	return value, found
+}
+
+func call(function string, arg Operand, params ...string) *CallOperand {
+	return &CallOperand{Function: function, Arg: arg, Params: params}
+}
+
+func field(name string) *FieldOperand {
+	return &FieldOperand{Field: name}
+}
+
+func TestValue(t *testing.T) {
+	q := valueQueryable{
+		"name":  "café",
+		"env":   " Prod ",
+		"image": "registry.local/team/nginx:1.25",
+		"empty": []string{},
+		"tags":  []string{"a", "b", "c"},
+		"exit":  1,
+	}
+
+	cases := []struct {
+		comp     *ValueExpr
+		expected bool
+	}{
+		{&ValueExpr{Left: call("len", field("name")), Operator: "=", Value: "4"}, true},
+		{&ValueExpr{Left: call("len", field("name")), Operator: ">", Value: "4"}, false},
+		{&ValueExpr{Left: call("len", field("tags")), Operator: "=", Value: "3"}, true},
+		{&ValueExpr{Left: call("len", field("tags")), Operator: "!=", Value: "3"}, false},
+		{&ValueExpr{Left: call("len", field("empty")), Operator: "=", Value: "0"}, true},
+		{&ValueExpr{Left: call("count", field("tags")), Operator: ">=", Value: "3"}, true},
+		{&ValueExpr{Left: call("count", field("tags")), Operator: "<", Value: "3"}, false},
+		{&ValueExpr{Left: call("count", field("tags")), Operator: "<=", Value: "3"}, true},
+
+		{&ValueExpr{Left: call("lower", call("trim", field("env"))), Operator: "=", Value: "prod"}, true},
+		{&ValueExpr{Left: call("upper", field("env")), Operator: "~", Value: "prod"}, true},
+		{&ValueExpr{Left: call("basename", field("image")), Operator: "=", Value: "nginx:1.25"}, true},
+		{&ValueExpr{Left: call("split", field("image"), ":"), Operator: "=", Value: "1.25"}, true},
+		{&ValueExpr{Left: call("split", field("image"), ":"), Operator: "!~", Value: "team"}, false},
+		{&ValueExpr{Left: &IndexOperand{List: call("split", field("image"), ":"), Index: 1}, Operator: "=", Value: "1.25"}, true},
+		{&ValueExpr{Left: &IndexOperand{List: call("split", field("image"), "/"), Index: -1}, Operator: "=", Value: "nginx:1.25"}, true},
+
+		// missing fields, out of range indexes and values the function does not apply to never match
+		{&ValueExpr{Left: call("len", field("missing")), Operator: "=", Value: "0"}, false},
+		{&ValueExpr{Left: call("len", field("exit")), Operator: "=", Value: "1"}, false},
+		{&ValueExpr{Left: call("count", field("name")), Operator: "=", Value: "1"}, false},
+		{&ValueExpr{Left: call("lower", field("missing")), Operator: "!=", Value: "prod"}, false},
+		{&ValueExpr{Left: &IndexOperand{List: call("split", field("image"), ":"), Index: 2}, Operator: "!=", Value: "x"}, false},
+	}
+
+	for _, cas := range cases {
+		require.Equal(t, cas.expected, cas.comp.Match(q), "%v", cas.comp)
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	RegisterFunction("semver", Function{
+		Inputs: []Type{TypeString},
+		Output: TypeBool,
+		Apply: func(value interface{}, _ []string) (interface{}, bool) {
+			v, ok := value.(string)
+			return strings.Count(v, ".") == 2, ok
+		},
+	})
+	defer delete(functions, "semver")
+
+	fields := map[string][]Operator{"version": {EQ, LIKE}}
+	types := Types(map[string]Type{"version": TypeString})
+
+	ast, err := Parse("semver(version)", fields, types)
+	require.NoError(t, err)
+	require.True(t, ast.Match(valueQueryable{"version": "1.2.3"}))
+	require.False(t, ast.Match(valueQueryable{"version": "1.2"}))
+
+	_, err = Parse("semver(version)=1", fields, types)
+	require.Error(t, err)
+
+	require.Panics(t, func() { RegisterFunction("any", Function{Apply: functions["len"].Apply}) })
+}
+
+type byteSize int64
+
+func TestValuesIs(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		value    interface{}
+		operator Operator
+		other    interface{}
+		expected bool
+	}{
+		{"a", EQ, "a", true},
+		{"abc", LIKE, "B", true},
+		{"a", EQ, "b", false},
+		{[]string{"a", "b"}, EQ, "b", true},
+		{"b", EQ, []string{"a", "b"}, true},
+		{[]string{"a", "b"}, EQ, []string{"c", "b"}, true},
+		{[]string{"a", "b"}, EQ, []string{"c"}, false},
+		{1, EQ, 1, true},
+		{2, GT, 1, true},
+		{byteSize(2048), GT, byteSize(1024), true},
+		{byteSize(2048), EQ, 2048, true},
+		{now.Add(-time.Hour), GT, now, true},
+		{now, GT, now.Add(-time.Hour), false},
+		{now, EQ, now, true},
+		{true, EQ, true, true},
+		{1, LT, 2, true},
+		{2, LE, 2, true},
+		{1, GE, 2, false},
+		{now, LT, now.Add(-time.Hour), true},
+		{"a", NE, "b", true},
+		{[]string{"a", "b"}, NE, "b", false},
+		{[]string{"a", "b"}, NE, []string{"c"}, true},
+
+		// values of different types never match
+		{"1", EQ, 1, false},
+		{now, EQ, "now", false},
+	}
+
+	for _, cas := range cases {
+		require.Equal(t, cas.expected, valuesIs(cas.value, cas.operator, cas.other), "%v %v %v", cas.value, cas.operator, cas.other)
+	}
+
+	q := valueQueryable{"image": "nginx", "label.image": "nginx"}
+	ref := &RefOperand{FieldOperand{Field: "image"}}
+	require.True(t, (&ValueExpr{Left: field("label.image"), Operator: "=", Right: ref}).Match(q))
+	require.False(t, (&ValueExpr{Left: field("label.image"), Operator: "!=", Right: ref}).Match(q))
+	require.False(t, (&ValueExpr{Left: field("label.missing"), Operator: "!=", Right: ref}).Match(q))
+}
@@ -3,20 +3,27 @@ package query
 import (
 	"bytes"
 	"fmt"
+	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
 type tokenClass string
 
 const (
-	tkLparen  tokenClass = "("
-	tkRparen             = ")"
-	tkLiteral            = "LITERAL"
-	tkAnd                = "&"
-	tkOr                 = "|"
-	tkCompOp             = "comp"
-	tkNot                = "!"
-	tkEOF                = "$"
+	tkLparen   tokenClass = "("
+	tkRparen              = ")"
+	tkLiteral             = "LITERAL"
+	tkAnd                 = "&"
+	tkOr                  = "|"
+	tkCompOp              = "comp"
+	tkNot                 = "!"
+	tkComma               = ","
+	tkLbracket            = "["
+	tkRbracket            = "]"
+	tkRef                 = "REF"
+	tkArith               = "arith"
+	tkEOF                 = "$"
 )
 
 type token struct {
@@ -25,11 +32,66 @@ type token struct {
 	pos   int
 }
 
+// description describes a token class in the error messages
+func (c tokenClass) description() string {
+	switch c {
+	case tkLiteral:
+		return "a value"
+	case tkCompOp:
+		return "an operator"
+	case tkRef:
+		return "a $field"
+	case tkArith:
+		return "an arithmetic operator"
+	case tkEOF:
+		return "the end of the query"
+	default:
+		return fmt.Sprintf("%q", string(c))
+	}
+}
+
+// description describes a token in the error messages, e.g. "name" or $created
+func (t token) description() string {
+	switch t.class {
+	case tkEOF:
+		return t.class.description()
+	case tkRef:
+		return "$" + t.value
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
+
 type lexer struct {
 	input string
 	start int
 	pos   int
 	width int
+
+	// queries are the named queries which can be referenced with @name
+	queries map[string]string
+	// expansions are the named queries being expanded at the current position
+	expansions []expansion
+
+	// calls records, for every open parenthesis, whether it opens the arguments of a function call,
+	// where commas separate the arguments instead of being part of the literals
+	calls []bool
+	// quoted is true if the last token is a quoted literal
+	quoted bool
+	// callable is true if the last token is a function name
+	callable bool
+	// indexable is true if the last token ends a function call or an index, e.g. split(image, ":")[0]
+	indexable bool
+	// indexing is true between the brackets of an index
+	indexing bool
+	// annotated is true once a comment or a @name reference was lexed
+	annotated bool
+}
+
+// expansion records the named query expanded in the input up to (excluding) the end position
+type expansion struct {
+	name string
+	end  int
 }
 
 func newLexer(input string) *lexer {
@@ -41,11 +103,30 @@ func newLexer(input string) *lexer {
 }
 
 func (lx *lexer) next() token {
-	for {
-		for lx.peek() == ' ' {
-			lx.pop()
+	res := lx.lex()
+	lx.indexable = res.class == tkRbracket
+	switch res.class {
+	case tkLparen:
+		lx.calls = append(lx.calls, lx.callable)
+	case tkRparen:
+		if n := len(lx.calls); n != 0 {
+			lx.indexable = lx.calls[n-1]
+			lx.calls = lx.calls[:n-1]
 		}
-		lx.drop()
+	case tkLbracket:
+		lx.indexing = true
+	case tkRbracket:
+		lx.indexing = false
+	}
+	_, isFunction := functions[res.value]
+	lx.callable = res.class == tkLiteral && !lx.quoted && isFunction
+	return res
+}
+
+func (lx *lexer) lex() token {
+	lx.quoted = false
+	for {
+		lx.skipBlanks()
 
 		r := lx.pop()
 		switch {
@@ -90,25 +171,89 @@ func (lx *lexer) next() token {
 			default:
 				return lx.emit(tkCompOp)
 			}
+		case r == ',' && lx.inCall():
+			return lx.emit(tkComma)
+		case r == '[' && lx.indexable:
+			return lx.emit(tkLbracket)
+		case r == ']' && lx.indexing:
+			return lx.emit(tkRbracket)
+		case r == '$':
+			return lx.lexRef()
 		case r == '"':
 			return lx.lexString()
+		case r == '@':
+			lx.annotated = true
+			lx.expand()
+			continue
 		default:
-			for notIn(lx.peek(), notOkInLiteral) {
+			for notIn(lx.peek(), notOkInLiteral) && !lx.delimiter(lx.peek()) {
 				lx.pop()
 			}
+			if class, found := keywords[strings.ToLower(lx.matched())]; found {
+				return lx.emit(class)
+			}
+			if arithOperators[lx.matched()] {
+				return lx.emit(tkArith)
+			}
 			return lx.emit(tkLiteral)
 		}
 	}
 }
 
+/*
+skipBlanks skips the whitespace, including newlines, and the comments, which run from a # at the start of a word
+to the end of the line
+*/
+func (lx *lexer) skipBlanks() {
+	for {
+		switch r := lx.peek(); {
+		case unicode.IsSpace(r):
+			lx.pop()
+		case r == '#':
+			lx.annotated = true
+			for r != eof && r != '\n' {
+				lx.pop()
+				r = lx.peek()
+			}
+		default:
+			lx.drop()
+			return
+		}
+	}
+}
+
+// inCall returns true between the parenthesis of a function call
+func (lx *lexer) inCall() bool {
+	return len(lx.calls) != 0 && lx.calls[len(lx.calls)-1]
+}
+
+// delimiter returns true if r ends a literal in the current context: commas in function calls and brackets in indexes
+func (lx *lexer) delimiter(r rune) bool {
+	return (r == ',' && lx.inCall()) || (r == ']' && lx.indexing)
+}
+
+// lexRef lexes a $name reference to a field
+func (lx *lexer) lexRef() token {
+	for notIn(lx.peek(), notOkInLiteral) && !lx.delimiter(lx.peek()) {
+		lx.pop()
+	}
+	name := strings.TrimPrefix(lx.matched(), "$")
+	if len(name) == 0 {
+		lx.errorf("was expecting a field name after $")
+	}
+	return lx.emitV(tkRef, name)
+}
+
 func (lx *lexer) lexString() token {
+	lx.quoted = true
 	lx.drop() // get rid of the opening quotes "
 	var buffer bytes.Buffer
 	for {
 		r := lx.pop()
 		switch r {
 		case eof:
-			panic("unclosed string")
+			lx.start-- // point at the opening quotes
+			lx.errorf("unclosed string")
 		case '\\':
 			if lx.peek() == '"' {
 				buffer.WriteRune(lx.pop())
@@ -124,6 +269,49 @@ func (lx *lexer) lexString() token {
 
 }
 
+/*
+expand replaces a @name reference to a named query with the query text, wrapped in parenthesis, in the lexer input.
+The lexing then resumes from the start of the expanded text, so that positions, and hence error messages,
+refer to the expanded input.
+*/
+func (lx *lexer) expand() {
+	for notIn(lx.peek(), notOkInLiteral) {
+		lx.pop()
+	}
+	ref := lx.matched()
+	name := strings.TrimPrefix(ref, "@")
+	if len(name) == 0 {
+		lx.errorf("was expecting a query name after @")
+	}
+	query, found := lx.queries[name]
+	if !found {
+		lx.errorf("Unknown query %s", ref)
+	}
+
+	active := lx.expansions[:0]
+	for _, e := range lx.expansions {
+		if e.end > lx.start {
+			active = append(active, e)
+		}
+	}
+	lx.expansions = active
+	for _, e := range lx.expansions {
+		if e.name == name {
+			lx.errorf("query %s references itself", ref)
+		}
+	}
+
+	replacement := "(" + query + ")"
+	delta := len(replacement) - len(ref)
+	for i := range lx.expansions {
+		lx.expansions[i].end += delta
+	}
+	lx.expansions = append(lx.expansions, expansion{name: name, end: lx.start + len(replacement)})
+
+	lx.input = lx.input[:lx.start] + replacement + lx.input[lx.pos:]
+	lx.pos = lx.start
+}
+
 func notIn(needle rune, haystack []rune) bool {
 	for _, r := range haystack {
 		if needle == r {
@@ -134,7 +322,24 @@ func notIn(needle rune, haystack []rune) bool {
 }
 
 var (
-	notOkInLiteral = []rune{eof, ' ', '(', ')', '~', '=', '!', '&', '|', '<', '>'}
+	// keywords are the case-insensitive word forms of the boolean operators.
+	// They must be quoted to be used as literals.
+	keywords = map[string]tokenClass{
+		"and": tkAnd,
+		"or":  tkOr,
+		"not": tkNot,
+	}
+
+	// arithOperators are the arithmetic operators, which are only recognized on their own, e.g. "created - 1h",
+	// so that literals like "library/redis" are left intact
+	arithOperators = map[string]bool{
+		"+": true,
+		"-": true,
+		"*": true,
+		"/": true,
+	}
+
+	notOkInLiteral = []rune{eof, ' ', '\t', '\n', '\r', '(', ')', '~', '=', '!', '&', '|', '<', '>'}
 )
 
 const eof = -1
@@ -171,8 +376,16 @@ func (l *lexer) matched() string {
 	return l.input[l.start:l.pos]
 }
 
+/*
+posError records the position of the offending input when it is not the last matched token, e.g. ahead of it in the lexer
+*/
+type posError struct {
+	pos     int
+	message string
+}
+
 func (l *lexer) errorf(format string, args ...interface{}) {
-	panic(fmt.Sprintf(format, args...))
+	panic(posError{pos: l.start, message: fmt.Sprintf(format, args...)})
 }
 
 func (l *lexer) emit(class tokenClass) token {
@@ -129,3 +129,142 @@ func TestLexer(t *testing.T) {
 
 	require.Equal(t, token{class: tkEOF, value: "", pos: 105}, lx.next(), "was expecting EOF")
 }
+
+func TestLexerNamedQueries(t *testing.T) {
+	lx := newLexer("@stale & label.team=infra")
+	lx.queries = map[string]string{"stale": "!running"}
+
+	expected := []token{
+		{class: tkLparen, value: "(", pos: 0},
+		{class: tkNot, value: "!", pos: 1},
+		{class: tkLiteral, value: "running", pos: 2},
+		{class: tkRparen, value: ")", pos: 9},
+		{class: tkAnd, value: "&", pos: 11},
+		{class: tkLiteral, value: "label.team", pos: 13},
+		{class: tkCompOp, value: "=", pos: 23},
+		{class: tkLiteral, value: "infra", pos: 24},
+		{class: tkEOF, value: "", pos: 29},
+	}
+
+	for _, exTk := range expected {
+		require.Equal(t, exTk, lx.next())
+	}
+	require.Equal(t, "(!running) & label.team=infra", lx.input)
+}
+
+func TestLexerKeywords(t *testing.T) {
+	lx := newLexer(`running and NOT(name~api Or name="or") AND notice=and`)
+	expected := []token{
+		{class: tkLiteral, value: "running", pos: 0},
+		{class: tkAnd, value: "and", pos: 8},
+		{class: tkNot, value: "NOT", pos: 12},
+		{class: tkLparen, value: "(", pos: 15},
+		{class: tkLiteral, value: "name", pos: 16},
+		{class: tkCompOp, value: "~", pos: 20},
+		{class: tkLiteral, value: "api", pos: 21},
+		{class: tkOr, value: "Or", pos: 25},
+		{class: tkLiteral, value: "name", pos: 28},
+		{class: tkCompOp, value: "=", pos: 32},
+		{class: tkLiteral, value: "or", pos: 34},
+		{class: tkRparen, value: ")", pos: 37},
+		{class: tkAnd, value: "AND", pos: 39},
+		{class: tkLiteral, value: "notice", pos: 43},
+		{class: tkCompOp, value: "=", pos: 49},
+		{class: tkAnd, value: "and", pos: 50},
+		{class: tkEOF, value: "", pos: 53},
+	}
+
+	for _, exTk := range expected {
+		require.Equal(t, exTk, lx.next())
+	}
+}
+
+func TestLexerCalls(t *testing.T) {
+	lx := newLexer(`split(a,",")[1]=x,y & (b=c,d[0]) & "len"(e,f)`)
+	expected := []token{
+		{class: tkLiteral, value: "split", pos: 0},
+		{class: tkLparen, value: "(", pos: 5},
+		{class: tkLiteral, value: "a", pos: 6},
+		{class: tkComma, value: ",", pos: 7},
+		{class: tkLiteral, value: ",", pos: 9},
+		{class: tkRparen, value: ")", pos: 11},
+		{class: tkLbracket, value: "[", pos: 12},
+		{class: tkLiteral, value: "1", pos: 13},
+		{class: tkRbracket, value: "]", pos: 14},
+		{class: tkCompOp, value: "=", pos: 15},
+		{class: tkLiteral, value: "x,y", pos: 16},
+		{class: tkAnd, value: "&", pos: 20},
+		{class: tkLparen, value: "(", pos: 22},
+		{class: tkLiteral, value: "b", pos: 23},
+		{class: tkCompOp, value: "=", pos: 24},
+		{class: tkLiteral, value: "c,d[0]", pos: 25},
+		{class: tkRparen, value: ")", pos: 31},
+		{class: tkAnd, value: "&", pos: 33},
+		{class: tkLiteral, value: "len", pos: 36},
+		{class: tkLparen, value: "(", pos: 40},
+		{class: tkLiteral, value: "e,f", pos: 41},
+		{class: tkRparen, value: ")", pos: 44},
+		{class: tkEOF, value: "", pos: 45},
+	}
+
+	for _, e := range expected {
+		require.Equal(t, e, lx.next())
+	}
+}
+
+func TestLexerRefs(t *testing.T) {
+	lx := newLexer(`a=$b.c & a="$b"`)
+	expected := []token{
+		{class: tkLiteral, value: "a", pos: 0},
+		{class: tkCompOp, value: "=", pos: 1},
+		{class: tkRef, value: "b.c", pos: 2},
+		{class: tkAnd, value: "&", pos: 7},
+		{class: tkLiteral, value: "a", pos: 9},
+		{class: tkCompOp, value: "=", pos: 10},
+		{class: tkLiteral, value: "$b", pos: 12},
+		{class: tkEOF, value: "", pos: 15},
+	}
+
+	for _, e := range expected {
+		require.Equal(t, e, lx.next())
+	}
+}
+
+func TestLexerArithmetic(t *testing.T) {
+	lx := newLexer(`a>$b - 1h*2 + 3/4 & c=library/redis-1`)
+	expected := []token{
+		{class: tkLiteral, value: "a", pos: 0},
+		{class: tkCompOp, value: ">", pos: 1},
+		{class: tkRef, value: "b", pos: 2},
+		{class: tkArith, value: "-", pos: 5},
+		{class: tkLiteral, value: "1h*2", pos: 7},
+		{class: tkArith, value: "+", pos: 12},
+		{class: tkLiteral, value: "3/4", pos: 14},
+		{class: tkAnd, value: "&", pos: 18},
+		{class: tkLiteral, value: "c", pos: 20},
+		{class: tkCompOp, value: "=", pos: 21},
+		{class: tkLiteral, value: "library/redis-1", pos: 22},
+		{class: tkEOF, value: "", pos: 37},
+	}
+
+	for _, e := range expected {
+		require.Equal(t, e, lx.next())
+	}
+}
+
+func TestLexerBlanks(t *testing.T) {
+	lx := newLexer("# stale containers\n\t!running # not running\r\n\t& name=a#b\n#")
+	expected := []token{
+		{class: tkNot, value: "!", pos: 20},
+		{class: tkLiteral, value: "running", pos: 21},
+		{class: tkAnd, value: "&", pos: 45},
+		{class: tkLiteral, value: "name", pos: 47},
+		{class: tkCompOp, value: "=", pos: 51},
+		{class: tkLiteral, value: "a#b", pos: 52},
+		{class: tkEOF, value: "", pos: 57},
+	}
+
+	for _, e := range expected {
+		require.Equal(t, e, lx.next())
+	}
+}
@@ -0,0 +1,159 @@
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+/*
+MapFields is the fields map to pass to Parse for the queryables returned by FromMap and FromJSON,
+accepting any field with every operator, as documents have no schema
+*/
+var MapFields = map[string][]Operator{
+	"*": {IS, EQ, NE, LIKE, GT, GE, LT, LE},
+}
+
+/*
+FromMap returns a queryable over a decoded JSON document, or any map of maps, slices and scalars.
+
+Fields are dotted paths, e.g. user.name, keys containing dots being matched before the nested maps.
+When a path goes through an array, the field has the values of every element, and matches if any of them matches,
+e.g. items.price>10 matches if at least one of the items costs more than 10.
+Whole numbers are compared as integers, other numbers as floats, and null values are missing.
+*/
+func FromMap(m map[string]interface{}) ValueQueryable {
+	return mapQueryable(m)
+}
+
+// FromJSON decodes a JSON object and returns a queryable over it, like FromMap
+func FromJSON(data []byte) (ValueQueryable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%s is not a JSON object", data)
+	}
+	return mapQueryable(m), nil
+}
+
+type mapQueryable map[string]interface{}
+
+var _ SliceQueryable = mapQueryable{}
+
+func (m mapQueryable) Is(field string, operator Operator, value string) bool {
+	values, _, found := m.lookup(field)
+	if operator == NE {
+		return found && !m.Is(field, EQ, value)
+	}
+	for _, v := range values {
+		if isValue(v, true, operator, value) {
+			return true
+		}
+	}
+	return found && operator == IS && len(values) == 0
+}
+
+/*
+Value returns the value of a field, the values of the fields going through arrays being formatted as a []string.
+It is false if the document has no value for the field.
+*/
+func (m mapQueryable) Value(field string) (interface{}, bool) {
+	values, multi, found := m.lookup(field)
+	switch {
+	case !found:
+		return nil, false
+	case !multi:
+		return values[0], true
+	}
+	res := make([]string, len(values))
+	for i, v := range values {
+		res[i] = fmt.Sprint(v)
+	}
+	return res, true
+}
+
+func (m mapQueryable) Elements(field string) []Queryable {
+	values, _, _ := m.lookup(field)
+	res := make([]Queryable, len(values))
+	for i, v := range values {
+		res[i] = valueElement{field: field, value: v}
+	}
+	return res
+}
+
+// lookup returns the values of a field, whether it goes through arrays, and false if it has no value
+func (m mapQueryable) lookup(field string) ([]interface{}, bool, bool) {
+	values, multi := lookupPath(map[string]interface{}(m), strings.Split(field, "."))
+	return values, multi, multi || len(values) != 0
+}
+
+func lookupPath(value interface{}, path []string) ([]interface{}, bool) {
+	if len(path) == 0 {
+		if elements, ok := value.([]interface{}); ok {
+			var res []interface{}
+			for _, element := range elements {
+				values, _ := lookupPath(element, nil)
+				res = append(res, values...)
+			}
+			return res, true
+		}
+		if v, ok := normalize(value); ok {
+			return []interface{}{v}, false
+		}
+		return nil, false
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// the longest key is tried first, so that keys containing dots, e.g. "app.kubernetes.io/name", can be queried
+		for i := len(path); i > 0; i-- {
+			if child, found := v[strings.Join(path[:i], ".")]; found {
+				return lookupPath(child, path[i:])
+			}
+		}
+	case []interface{}:
+		var res []interface{}
+		for _, element := range v {
+			values, _ := lookupPath(element, path)
+			res = append(res, values...)
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// normalize converts the JSON numbers to int or float64, and returns false for null values
+func normalize(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i), true
+		}
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int(v), true
+		}
+		return v, true
+	case float32:
+		return normalize(float64(v))
+	}
+	if i, ok := toInt(value); ok {
+		return int(i), true
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(reflect.ValueOf(value).Uint()), true
+	}
+	return value, true
+}
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromJSON(t *testing.T) {
+	docs := []string{
+		`{"id": 1, "level": "error", "user": {"name": "alice", "admin": true}, "items": [{"price": 12.5}, {"price": 3}], "tags": ["a", "b"]}`,
+		`{"id": 2, "level": "info", "user": {"name": "bob", "admin": false}, "items": [], "duration": null, "app.kubernetes.io/name": "web"}`,
+		`{"id": 3, "level": "warn", "user": null, "items": [{"price": 10}], "tags": "c", "ratio": 0.25}`,
+	}
+
+	cases := []struct {
+		query    string
+		expected []int
+	}{
+		{"level=error", []int{1}},
+		{"level!=error", []int{2, 3}},
+		{"id>=2", []int{2, 3}},
+		{"id<2.5", []int{1, 2}},
+		{"user.name~LI", []int{1}},
+		{"user.admin", []int{1}},
+		{"user.admin=false", []int{2}},
+		{"user is null", []int{3}},
+		{"user.name!=alice", []int{2}},
+		{"items.price>10", []int{1}},
+		{"items.price=3", []int{1}},
+		{"items.price!=10", []int{1, 2}},
+		{"all(items.price>=10)", []int{2, 3}},
+		{"count(items.price)=0", []int{2}},
+		{"tags=c | tags=b", []int{1, 3}},
+		{"len(tags)=2", []int{1}},
+		{"duration is null", []int{1, 2, 3}},
+		{"ratio<0.5 & ratio>0.2", []int{3}},
+		{"app.kubernetes.io/name=web", []int{2}},
+		{"items", []int{1, 2, 3}},
+		{"missing", nil},
+	}
+
+	var queryables []ValueQueryable
+	for _, doc := range docs {
+		q, err := FromJSON([]byte(doc))
+		require.NoError(t, err, doc)
+		queryables = append(queryables, q)
+	}
+
+	for _, cas := range cases {
+		ast, err := Parse(cas.query, MapFields)
+		require.NoError(t, err, cas.query)
+
+		var actual []int
+		for i, q := range queryables {
+			if ast.Match(q) {
+				actual = append(actual, i+1)
+			}
+		}
+		require.Equal(t, cas.expected, actual, cas.query)
+	}
+
+	for _, doc := range []string{`[1, 2]`, `null`, `{"a": `} {
+		_, err := FromJSON([]byte(doc))
+		require.Error(t, err, doc)
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	q := FromMap(map[string]interface{}{
+		"count": 3.0,
+		"size":  int64(42),
+		"items": []interface{}{map[string]interface{}{"name": "x"}, map[string]interface{}{"name": "y"}},
+	})
+
+	value, found := q.Value("count")
+	require.True(t, found)
+	require.Equal(t, 3, value)
+
+	value, found = q.Value("size")
+	require.True(t, found)
+	require.Equal(t, 42, value)
+
+	value, found = q.Value("items.name")
+	require.True(t, found)
+	require.Equal(t, []string{"x", "y"}, value)
+
+	_, found = q.Value("items.missing")
+	require.True(t, found, "fields going through arrays are empty lists")
+	_, found = q.Value("missing")
+	require.False(t, found)
+}
@@ -0,0 +1,364 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+jsonNode is the JSON representation of the expressions and operands, e.g. {"type": "comparison", "field": "name", "operator": "~", "value": "web"}.
+The type is one of or, and, not, comparison, null, quantifier and value for the expressions,
+and field, ref, call, index, literal and arithmetic for the operands.
+*/
+type jsonNode struct {
+	Type       string    `json:"type"`
+	Quantifier string    `json:"quantifier,omitempty"`
+	Function   string    `json:"function,omitempty"`
+	Field      string    `json:"field,omitempty"`
+	Operator   string    `json:"operator,omitempty"`
+	Value      string    `json:"value,omitempty"`
+	Text       string    `json:"text,omitempty"`
+	Negated    bool      `json:"negated,omitempty"`
+	Derived    bool      `json:"derived,omitempty"`
+	Left       *jsonNode `json:"left,omitempty"`
+	Right      *jsonNode `json:"right,omitempty"`
+	Expression *jsonNode `json:"expression,omitempty"`
+	Arg        *jsonNode `json:"arg,omitempty"`
+	Params     []string  `json:"params,omitempty"`
+	List       *jsonNode `json:"list,omitempty"`
+	Index      *int      `json:"index,omitempty"`
+}
+
+/*
+UnmarshalExpression decodes an expression encoded in JSON, e.g. by json.Marshal.
+The expression is not validated, use ParseJSON to check it against the queryable fields.
+*/
+func UnmarshalExpression(data []byte) (Expression, error) {
+	var node jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	return node.expression()
+}
+
+/*
+ParseJSON decodes an expression encoded in JSON, e.g. built by a UI, and validates it like Parse would do for the same query,
+e.g. by checking that its fields exist and support its operators, and that its functions and quantifiers are known.
+The field names and operators must be written as in a query, e.g. {"field": "name", "operator": "~"}:
+a field name like "running #" or an operator like "=x | running" is rejected instead of changing the query.
+The validation failures are returned as a ParseError without input, and the decoding ones as is.
+*/
+func ParseJSON(data []byte, fields map[string][]Operator, options ...Option) (Expression, error) {
+	expr, err := UnmarshalExpression(data)
+	if err != nil {
+		return nil, err
+	}
+	return validate(expr, fields, options...)
+}
+
+func marshalExpression(expr Expression) ([]byte, error) {
+	node, err := expressionNode(expr)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(node)
+}
+
+// unmarshalExpression decodes an expression of the provided type
+func unmarshalExpression(data []byte, typ string) (Expression, error) {
+	var node jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if node.Type != typ {
+		return nil, fmt.Errorf("expected an expression of type %q, got %q", typ, node.Type)
+	}
+	return node.expression()
+}
+
+func (or *OrExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(or)
+}
+
+func (or *OrExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "or")
+	if err == nil {
+		*or = *expr.(*OrExpr)
+	}
+	return err
+}
+
+func (and *AndExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(and)
+}
+
+func (and *AndExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "and")
+	if err == nil {
+		*and = *expr.(*AndExpr)
+	}
+	return err
+}
+
+func (not *NotExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(not)
+}
+
+func (not *NotExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "not")
+	if err == nil {
+		*not = *expr.(*NotExpr)
+	}
+	return err
+}
+
+func (c *CompExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(c)
+}
+
+func (c *CompExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "comparison")
+	if err == nil {
+		*c = *expr.(*CompExpr)
+	}
+	return err
+}
+
+func (n *NullExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(n)
+}
+
+func (n *NullExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "null")
+	if err == nil {
+		*n = *expr.(*NullExpr)
+	}
+	return err
+}
+
+func (q *QuantExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(q)
+}
+
+func (q *QuantExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "quantifier")
+	if err == nil {
+		*q = *expr.(*QuantExpr)
+	}
+	return err
+}
+
+func (c *ValueExpr) MarshalJSON() ([]byte, error) {
+	return marshalExpression(c)
+}
+
+func (c *ValueExpr) UnmarshalJSON(data []byte) error {
+	expr, err := unmarshalExpression(data, "value")
+	if err == nil {
+		*c = *expr.(*ValueExpr)
+	}
+	return err
+}
+
+func expressionNode(expr Expression) (*jsonNode, error) {
+	var err error
+	node := &jsonNode{}
+	switch e := expr.(type) {
+	case *OrExpr:
+		node.Type = "or"
+		if node.Left, err = expressionNode(e.Left); err == nil {
+			node.Right, err = expressionNode(e.Right)
+		}
+	case *AndExpr:
+		node.Type = "and"
+		if node.Left, err = expressionNode(e.Left); err == nil {
+			node.Right, err = expressionNode(e.Right)
+		}
+	case *NotExpr:
+		node.Type = "not"
+		node.Expression, err = expressionNode(e.Expression)
+	case *CompExpr:
+		node.Type, node.Field, node.Operator, node.Value, node.Derived = "comparison", e.Field, e.Operator, e.Value, e.Derived
+	case *NullExpr:
+		node.Type, node.Field, node.Negated = "null", e.Field, e.Negated
+	case *QuantExpr:
+		node.Type, node.Quantifier, node.Field = "quantifier", e.Quantifier, e.Field
+		node.Expression, err = expressionNode(e.Expression)
+	case *ValueExpr:
+		node.Type, node.Operator, node.Value = "value", e.Operator, e.Value
+		if node.Left, err = operandNode(e.Left); err == nil && e.Right != nil {
+			node.Right, err = operandNode(e.Right)
+		}
+	default:
+		return nil, fmt.Errorf("cannot marshal expression %T", expr)
+	}
+	return node, err
+}
+
+func operandNode(op Operand) (*jsonNode, error) {
+	var err error
+	node := &jsonNode{}
+	switch o := op.(type) {
+	case *FieldOperand:
+		node.Type, node.Field = "field", o.Field
+	case *RefOperand:
+		node.Type, node.Field = "ref", o.Field
+	case *CallOperand:
+		node.Type, node.Function, node.Params = "call", o.Function, o.Params
+		node.Arg, err = operandNode(o.Arg)
+	case *IndexOperand:
+		index := o.Index
+		node.Type, node.Index = "index", &index
+		node.List, err = operandNode(o.List)
+	case *LiteralOperand:
+		node.Type, node.Text = "literal", o.Text
+	case *ArithOperand:
+		node.Type, node.Operator = "arithmetic", o.Operator
+		if node.Left, err = operandNode(o.Left); err == nil {
+			node.Right, err = operandNode(o.Right)
+		}
+	default:
+		return nil, fmt.Errorf("cannot marshal operand %T", op)
+	}
+	return node, err
+}
+
+// expression converts a decoded node to an expression, checking that it has the required children
+func (node *jsonNode) expression() (Expression, error) {
+	if err := node.require(); err != nil {
+		return nil, err
+	}
+	switch node.Type {
+	case "or", "and":
+		left, err := node.Left.expression()
+		if err != nil {
+			return nil, err
+		}
+		right, err := node.Right.expression()
+		if err != nil {
+			return nil, err
+		}
+		if node.Type == "or" {
+			return &OrExpr{Left: left, Right: right}, nil
+		}
+		return &AndExpr{Left: left, Right: right}, nil
+	case "not":
+		expr, err := node.Expression.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expression: expr}, nil
+	case "comparison":
+		return &CompExpr{Field: node.Field, Operator: node.Operator, Value: node.Value, Derived: node.Derived}, nil
+	case "null":
+		return &NullExpr{Field: node.Field, Negated: node.Negated}, nil
+	case "quantifier":
+		expr, err := node.Expression.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &QuantExpr{Quantifier: node.Quantifier, Field: node.Field, Expression: expr}, nil
+	case "value":
+		left, err := node.Left.operand()
+		if err != nil {
+			return nil, err
+		}
+		res := &ValueExpr{Left: left, Operator: node.Operator, Value: node.Value}
+		if node.Right != nil {
+			if res.Right, err = node.Right.operand(); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unknown expression type %q", node.Type)
+	}
+}
+
+// operand converts a decoded node to an operand, checking that it has the required children
+func (node *jsonNode) operand() (Operand, error) {
+	if err := node.require(); err != nil {
+		return nil, err
+	}
+	switch node.Type {
+	case "field":
+		return &FieldOperand{Field: node.Field}, nil
+	case "ref":
+		return &RefOperand{FieldOperand{Field: node.Field}}, nil
+	case "call":
+		arg, err := node.Arg.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &CallOperand{Function: node.Function, Arg: arg, Params: node.Params}, nil
+	case "index":
+		list, err := node.List.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &IndexOperand{List: list, Index: *node.Index}, nil
+	case "literal":
+		res := &LiteralOperand{Text: node.Text}
+		if _, _, err := res.typed(); err != nil {
+			return nil, err
+		}
+		return res, nil
+	case "arithmetic":
+		left, err := node.Left.operand()
+		if err != nil {
+			return nil, err
+		}
+		right, err := node.Right.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &ArithOperand{Operator: node.Operator, Left: left, Right: right}, nil
+	default:
+		return nil, fmt.Errorf("unknown operand type %q", node.Type)
+	}
+}
+
+// require checks that a decoded node has the fields required by its type
+func (node *jsonNode) require() error {
+	var missing string
+	switch node.Type {
+	case "or", "and", "arithmetic":
+		if node.Left == nil {
+			missing = "left"
+		} else if node.Right == nil {
+			missing = "right"
+		}
+	case "not":
+		if node.Expression == nil {
+			missing = "expression"
+		}
+	case "quantifier":
+		if node.Expression == nil {
+			missing = "expression"
+		} else if len(node.Field) == 0 {
+			missing = "field"
+		}
+	case "comparison", "null", "field", "ref":
+		if len(node.Field) == 0 {
+			missing = "field"
+		}
+	case "value":
+		if node.Left == nil {
+			missing = "left"
+		}
+	case "call":
+		if node.Arg == nil {
+			missing = "arg"
+		}
+	case "index":
+		if node.List == nil {
+			missing = "list"
+		} else if node.Index == nil {
+			missing = "index"
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("%s node without %s", node.Type, missing)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	ast, err := Parse(`running & !(name~web | exit is not null)`, translateFields, Types(translateTypes))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(ast)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "and",
+		"left": {"type": "comparison", "field": "running"},
+		"right": {"type": "not", "expression": {
+			"type": "or",
+			"left": {"type": "comparison", "field": "name", "operator": "~", "value": "web"},
+			"right": {"type": "null", "field": "exit", "negated": true}
+		}}
+	}`, string(data))
+
+	ast, err = Parse(`len(split(name, ":")[0])>3 & exited>$created + 1h`, translateFields, Types(translateTypes))
+	require.NoError(t, err)
+	data, err = json.Marshal(ast.(*AndExpr).Right)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "value",
+		"left": {"type": "field", "field": "exited"},
+		"operator": ">",
+		"right": {"type": "arithmetic", "operator": "+", "left": {"type": "ref", "field": "created"}, "right": {"type": "literal", "text": "1h"}}
+	}`, string(data))
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	queries := []string{
+		`running & name~web | exit>0`,
+		// exit!="" is rejected like the other values which are not integers, the empty value is kept on a string field
+		`!(running | name="a b") & exit!=0 & name!=""`,
+		`exit is null | exit is not null`,
+		`all(tag~registry.local | tag=x) & none(label.env=prod)`,
+		`len(split(name, ":")[-1])>3 & count(tag)>=2`,
+		`exited>$created + 1h * 2 & size<2 * 500MB`,
+		`label.version!=$label.app`,
+	}
+
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			ast, err := Parse(query, translateFields, Types(translateTypes))
+			require.NoError(t, err)
+			data, err := json.Marshal(ast)
+			require.NoError(t, err)
+
+			decoded, err := UnmarshalExpression(data)
+			require.NoError(t, err)
+			require.Equal(t, Format(ast), Format(decoded))
+
+			parsed, err := ParseJSON(data, translateFields, Types(translateTypes))
+			require.NoError(t, err)
+			require.Equal(t, ast, parsed)
+			require.Equal(t, query, Format(parsed))
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var and AndExpr
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "and", "left": {"type": "comparison", "field": "running"}, "right": {"type": "comparison", "field": "name", "operator": "=", "value": "x"}}`), &and))
+	require.Equal(t, AndExpr{
+		Left:  &CompExpr{Field: "running"},
+		Right: &CompExpr{Field: "name", Operator: "=", Value: "x"},
+	}, and)
+
+	var or OrExpr
+	require.EqualError(t, json.Unmarshal([]byte(`{"type": "and"}`), &or), `expected an expression of type "or", got "and"`)
+
+	invalid := map[string]string{
+		`{"type": "xor"}`: `unknown expression type "xor"`,
+		`{"type": "and", "left": {"type": "comparison", "field": "running"}}`:                        "and node without right",
+		`{"type": "value", "left": {"type": "index", "list": {"type": "field", "field": "tag"}}}`:    "index node without index",
+		`{"type": "value", "left": {"type": "literal", "text": "x"}, "operator": "=", "value": "1"}`: "x is neither a number, a duration nor a size",
+	}
+	for data, msg := range invalid {
+		_, err := UnmarshalExpression([]byte(data))
+		require.EqualError(t, err, msg, data)
+	}
+}
+
+func TestParseJSONValidation(t *testing.T) {
+	_, err := ParseJSON([]byte(`{"type": "comparison", "field": "name", "operator": ">", "value": "x"}`), translateFields, Types(translateTypes))
+	require.Error(t, err)
+
+	_, err = ParseJSON([]byte(`{"type": "comparison", "field": "image", "operator": "=", "value": "x"}`), translateFields, Types(translateTypes))
+	require.Error(t, err)
+
+	ast, err := ParseJSON([]byte(`{"type": "comparison", "field": "name", "operator": "=", "value": "$APP"}`), translateFields, Variables(map[string]string{"APP": "web"}))
+	require.NoError(t, err)
+	require.Equal(t, &CompExpr{Field: "name", Operator: "=", Value: "$APP"}, ast)
+}
+
+func TestParseJSONInjection(t *testing.T) {
+	invalid := map[string]string{
+		// the names are not parsed as query text, e.g. # does not comment out the rest of the query
+		`{"type": "and", "left": {"type": "comparison", "field": "running #"}, "right": {"type": "comparison", "field": "name", "operator": "=", "value": "x"}}`: `Invalid field name "running #"`,
+		`{"type": "comparison", "field": "name", "operator": "=x | running & name=", "value": "y"}`:                                                              `Unknown operator "=x | running & name="`,
+		`{"type": "comparison", "field": "name=x | running"}`:                                                                                                    `Invalid field name "name=x | running"`,
+		`{"type": "null", "field": "exit)"}`: `Invalid field name "exit)"`,
+		`{"type": "quantifier", "quantifier": "some", "field": "tag", "expression": {"type": "comparison", "field": "tag", "operator": "=", "value": "x"}}`:                                                                           `Unknown quantifier "some"`,
+		`{"type": "value", "left": {"type": "call", "function": "lowr", "arg": {"type": "field", "field": "name"}}, "operator": "=", "value": "x"}`:                                                                                   "Unknown function lowr, did you mean lower?",
+		`{"type": "value", "left": {"type": "field", "field": "exited"}, "operator": ">", "right": {"type": "arithmetic", "operator": "%", "left": {"type": "ref", "field": "created"}, "right": {"type": "literal", "text": "1h"}}}`: `Unknown arithmetic operator "%"`,
+		`{"type": "comparison", "field": "exit", "operator": "=", "value": "zero"}`:                                                                                                                                                   "exit should be compared to an integer",
+		`{"type": "comparison", "field": "exit", "operator": "!=", "value": ""}`:                                                                                                                                                      "exit should be compared to an integer",
+		`{"type": "comparison", "field": "size", "operator": ">", "value": "abc"}`:                                                                                                                                                    "size should be compared to a size, e.g. 200MB",
+		`{"type": "value", "left": {"type": "call", "function": "len", "arg": {"type": "field", "field": "name"}}, "operator": "=", "value": "zero"}`:                                                                                 "len(name) should be compared to an integer",
+		`{"type": "comparison", "field": "name", "operator": ">", "value": "x"}`:                                                                                                                                                      "field name does not support operator >, supported operators: =, !=, ~, !~",
+		`{"type": "value", "left": {"type": "call", "function": "count", "arg": {"type": "field", "field": "name"}}, "operator": ">", "value": "1"}`:                                                                                  "count(...) cannot be applied to the string value name",
+	}
+	for data, msg := range invalid {
+		_, err := ParseJSON([]byte(data), translateFields, Types(translateTypes))
+		require.Equal(t, ParseError{Message: msg}, err, data)
+	}
+}
@@ -0,0 +1,118 @@
+package query
+
+import "regexp"
+
+/*
+ToMongo translates a query to a MongoDB filter document, which can be passed as is to the MongoDB drivers.
+
+The paths map the query fields to document paths, with the same keys as the fields map.
+The mappings of wildcard fields contain a %s, replaced by the rest of the field name,
+e.g. "label.*": "labels.%s" translates label.env=prod to {"labels.env": "prod"}.
+The optional types are used to convert the values, e.g. durations to the time they are the age of, like in created>2w.
+
+Negations are pushed down to the conditions, so that conditions on missing fields never match, even negated,
+like with missing values. Quantifiers, function calls, arithmetic and comparisons between fields cannot be translated,
+and return a TranslateError.
+*/
+func ToMongo(expr Expression, paths map[string]string, types map[string]Type) (filter map[string]interface{}, err error) {
+	defer recoverTranslation(&err)
+	t := &mongoTranslator{paths: paths, types: types}
+	return t.translate(expr, false), nil
+}
+
+// mongoOperators are the MongoDB equivalents of the ordering operators
+var mongoOperators = map[string]string{
+	">":  "$gt",
+	">=": "$gte",
+	"<":  "$lt",
+	"<=": "$lte",
+}
+
+type mongoTranslator struct {
+	paths map[string]string
+	types map[string]Type
+}
+
+func (t *mongoTranslator) path(expr Expression, field string) (string, Type) {
+	path := translateField(t.paths, expr, field, func(key string) string {
+		return key
+	})
+	return path, lookupType(t.types, field)
+}
+
+// translate returns the filter matching the documents matching the expression, or not matching it if negated
+func (t *mongoTranslator) translate(expr Expression, negated bool) map[string]interface{} {
+	switch e := expr.(type) {
+	case *OrExpr:
+		operator := "$or"
+		if negated {
+			operator = "$and"
+		}
+		return map[string]interface{}{operator: []interface{}{t.translate(e.Left, negated), t.translate(e.Right, negated)}}
+	case *AndExpr:
+		operator := "$and"
+		if negated {
+			operator = "$or"
+		}
+		return map[string]interface{}{operator: []interface{}{t.translate(e.Left, negated), t.translate(e.Right, negated)}}
+	case *NotExpr:
+		return t.translate(e.Expression, !negated)
+	case *CompExpr:
+		return t.comparison(e, negated)
+	case *NullExpr:
+		path, _ := t.path(e, e.Field)
+		if e.Negated != negated {
+			return map[string]interface{}{path: map[string]interface{}{"$ne": nil}}
+		}
+		return map[string]interface{}{path: nil}
+	case *QuantExpr:
+		untranslatable(expr, "quantifiers are not supported")
+	case *ValueExpr:
+		untranslatable(expr, "functions, arithmetic and comparisons between fields are not supported")
+	default:
+		untranslatable(expr, "unsupported expression %T", expr)
+	}
+	return nil
+}
+
+func (t *mongoTranslator) comparison(e *CompExpr, negated bool) map[string]interface{} {
+	path, typ := t.path(e, e.Field)
+	if len(e.Operator) == 0 {
+		switch {
+		case (typ == TypeBool || typ == TypeAny) && negated:
+			return map[string]interface{}{path: map[string]interface{}{"$ne": true}}
+		case typ == TypeBool || typ == TypeAny:
+			return map[string]interface{}{path: true}
+		case negated:
+			return map[string]interface{}{path: nil}
+		default:
+			return map[string]interface{}{path: map[string]interface{}{"$ne": nil}}
+		}
+	}
+
+	operator := e.Operator
+	if negated {
+		operator = negatedOperators[operator]
+	}
+	value, operator := translateValue(typ, operator, e.Value)
+	var condition interface{}
+	switch operator {
+	case "=":
+		condition = value
+	case "!=":
+		// $ne also matches the documents without the field
+		condition = map[string]interface{}{"$nin": []interface{}{value, nil}}
+	case "~":
+		condition = likeRegex(e.Value)
+	case "!~":
+		condition = map[string]interface{}{"$not": likeRegex(e.Value), "$ne": nil}
+	default:
+		condition = map[string]interface{}{mongoOperators[operator]: value}
+	}
+	return map[string]interface{}{path: condition}
+}
+
+// likeRegex returns a case insensitive regular expression matching the values containing a value
+func likeRegex(value string) map[string]interface{} {
+	return map[string]interface{}{"$regex": regexp.QuoteMeta(value), "$options": "i"}
+}
@@ -0,0 +1,72 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type m = map[string]interface{}
+
+func TestToMongo(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	defer func() { now = time.Now }()
+
+	paths := map[string]string{
+		"running": "state.running",
+		"name":    "name",
+		"tag":     "tags",
+		"exit":    "state.exit_code",
+		"created": "created_at",
+		"exited":  "state.finished_at",
+		"size":    "size",
+		"label.*": "labels.%s",
+	}
+
+	cases := []struct {
+		query    string
+		expected m
+	}{
+		{"running", m{"state.running": true}},
+		{"!running", m{"state.running": m{"$ne": true}}},
+		{"name=web | exit>0", m{"$or": []interface{}{m{"name": "web"}, m{"state.exit_code": m{"$gt": int64(0)}}}}},
+		{"name!=web", m{"name": m{"$nin": []interface{}{"web", nil}}}},
+		{"name~a.b & !name~c", m{"$and": []interface{}{
+			m{"name": m{"$regex": `a\.b`, "$options": "i"}},
+			m{"name": m{"$not": m{"$regex": "c", "$options": "i"}, "$ne": nil}},
+		}}},
+		{"created>2w", m{"created_at": m{"$lt": base.Add(-14 * 24 * time.Hour)}}},
+		{"!(exit>0 | size<1KB)", m{"$and": []interface{}{
+			m{"state.exit_code": m{"$lte": int64(0)}},
+			m{"size": m{"$gte": int64(1024)}},
+		}}},
+		{"label.env=prod & label.team", m{"$and": []interface{}{m{"labels.env": "prod"}, m{"labels.team": m{"$ne": nil}}}}},
+		{"exit is null", m{"state.exit_code": nil}},
+		{"!(exit is null)", m{"state.exit_code": m{"$ne": nil}}},
+		{"tag=latest", m{"tags": "latest"}},
+	}
+
+	for _, cas := range cases {
+		ast, err := Parse(cas.query, translateFields, Types(translateTypes))
+		require.NoError(t, err, cas.query)
+
+		filter, err := ToMongo(ast, paths, translateTypes)
+		require.NoError(t, err, cas.query)
+		require.Equal(t, cas.expected, filter, cas.query)
+	}
+
+	for _, query := range []string{
+		"any(tag=x)",
+		"len(tag)>2",
+		"exited<$created",
+	} {
+		ast, err := Parse(query, translateFields, Types(translateTypes))
+		require.NoError(t, err, query)
+
+		_, err = ToMongo(ast, paths, translateTypes)
+		require.Error(t, err, query)
+		t.Log(err)
+	}
+}
@@ -2,129 +2,637 @@ package query
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 type parser struct {
 	lexer   *lexer
 	matched token
 	next    token
+	// expected describes the tokens tried since the last matched token, reported if none of them is found
+	expected []string
+	// depth is the number of open parenthesis matched so far
+	depth int
+	// errors are the errors found so far, the parser skipping the invalid conditions to report the following ones
+	errors []ParseError
+	// fatal is set if the lexer failed, in which case the parsing cannot resume
+	fatal bool
+
+	// completion records what is expected at the end of the query, when completing a partial query,
+	// until the parser fails there
+	completion *Completion
+	completed  bool
+	// left and operator are the left side and operator of the comparison whose right side is being parsed
+	left     Operand
+	operator string
 
 	fields map[string][]Operator
+	types  map[string]Type
+
+	variables   map[string]string
+	environment func(name string) (string, bool)
 }
 
 // ParseError is returned if a query cannot be successfuly parsed
 type ParseError struct {
-	// The original query
+	// The original query, empty for the expressions validated by ParseJSON or Build
 	Input string
 	// The position where the parsing fails
 	Pos int
 	// The error message
 	Message string
+	// Expected describes the tokens which were expected at the error position, if the error is a syntax error
+	Expected []string
+}
+
+/*
+ParseErrors is returned instead of a ParseError if a query has several errors, e.g. unknown fields in different conditions,
+in the order of their positions
+*/
+type ParseErrors []ParseError
+
+// Error shows every error like ParseError does, one after the other
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
 }
 
+/*
+Error shows the message and the query with a caret under the error position.
+For multi-line queries, only the line of the error is shown, preceded by its number.
+*/
 func (e ParseError) Error() string {
-	return fmt.Sprintf("Parse error: %s\n%s\n%s^", e.Message, e.Input, strings.Repeat(" ", e.Pos))
+	if len(e.Input) == 0 {
+		return fmt.Sprintf("Parse error: %s", e.Message)
+	}
+	if !strings.Contains(e.Input, "\n") {
+		return fmt.Sprintf("Parse error: %s\n%s\n%s^", e.Message, e.Input, strings.Repeat(" ", e.Pos))
+	}
+	pos := e.Pos
+	if pos > len(e.Input) {
+		pos = len(e.Input)
+	}
+	if len(strings.TrimSpace(e.Input[pos:])) == 0 {
+		// point right after the query rather than on the trailing blank lines
+		pos = len(strings.TrimRightFunc(e.Input[:pos], unicode.IsSpace))
+	}
+	start := strings.LastIndex(e.Input[:pos], "\n") + 1
+	end := strings.Index(e.Input[pos:], "\n")
+	if end == -1 {
+		end = len(e.Input)
+	} else {
+		end += pos
+	}
+	line := strings.Count(e.Input[:start], "\n") + 1
+	// keep the tabs so that the caret is aligned with the error whatever the tab width
+	indent := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, e.Input[start:pos])
+	return fmt.Sprintf("Parse error at line %d: %s\n%s\n%s^", line, e.Message, e.Input[start:end], indent)
+}
+
+// Option customizes how a query is parsed
+type Option func(p *parser)
+
+/*
+NamedQueries makes the provided queries available by name in the parsed query: "@name" is replaced by the named query,
+wrapped in parenthesis.
+Named queries can reference other named queries.
+*/
+func NamedQueries(queries map[string]string) Option {
+	return func(p *parser) {
+		p.lexer.queries = queries
+	}
+}
+
+/*
+Variables makes the provided variables available in the parsed query: "$name" is replaced by the variable value,
+which is always handled as a single literal, whatever characters it contains.
+Variables take precedence over the fields referenced with "$field".
+*/
+func Variables(variables map[string]string) Option {
+	return func(p *parser) {
+		p.variables = variables
+	}
+}
+
+/*
+Environment makes the values returned by lookup, e.g. os.LookupEnv, available like variables for the "$name" references
+which are neither variables nor fields.
+*/
+func Environment(lookup func(name string) (string, bool)) Option {
+	return func(p *parser) {
+		p.environment = lookup
+	}
 }
 
 /*
 Parse accepts an input string and the list and types of valid fields and returns either a matcher expression if the query
-is valid, or else an error
+is valid, or else an error: a ParseError, or ParseErrors if the query has several errors
 */
-func Parse(input string, fields map[string][]Operator) (Expression, error) {
-	lexer := newLexer(input)
-	return (&parser{
-		lexer:  lexer,
-		next:   lexer.next(),
+func Parse(input string, fields map[string][]Operator, options ...Option) (Expression, error) {
+	p := &parser{
+		lexer:  newLexer(input),
 		fields: fields,
-	}).parse()
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p.parse()
 }
 
 func (p *parser) parse() (ast Expression, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			ast = nil
-			err = ParseError{
-				Input:   p.lexer.input,
-				Pos:     p.matched.pos,
-				Message: fmt.Sprintf("%v", r),
-			}
+			p.addError(p.parseError(r))
+		}
+		switch len(p.errors) {
+		case 0:
+		case 1:
+			ast, err = nil, p.errors[0]
+		default:
+			ast, err = nil, ParseErrors(p.errors)
 		}
 	}()
+	p.next = p.scan()
 	ast = p.or()
 	if !p.found(tkEOF) {
-		p.advance()
-		panic("Unexpected input")
+		p.unexpected()
 	}
 	return
 }
 
+// addError records an error, unless an error was already reported at the same position, e.g. an unexpected token
+func (p *parser) addError(err ParseError) {
+	// what is expected after an error is unreliable
+	p.completed = p.completed || p.next.class == tkEOF
+	if last := len(p.errors) - 1; last >= 0 && p.errors[last].Pos == err.Pos {
+		return
+	}
+	p.errors = append(p.errors, err)
+}
+
+// parseError converts a recovered panic to a ParseError
+func (p *parser) parseError(r interface{}) ParseError {
+	pErr := ParseError{
+		Input:   p.lexer.input,
+		Pos:     p.matched.pos,
+		Message: fmt.Sprintf("%v", r),
+	}
+	switch e := r.(type) {
+	case posError:
+		pErr.Pos, pErr.Message = e.pos, e.message
+	case syntaxError:
+		pErr.Pos, pErr.Message, pErr.Expected = e.pos, e.message, e.expected
+	}
+	return pErr
+}
+
 func (p *parser) or() Expression {
 	left := p.and()
 	for p.found(tkOr) {
 		right := p.and()
-		left = &exprOr{left, right}
+		left = &OrExpr{left, right}
 	}
 	return left
 }
 
 func (p *parser) and() Expression {
-	left := p.atom()
+	left := p.condition()
 	for p.found(tkAnd) {
-		right := p.atom()
-		left = &exprAnd{left, right}
+		right := p.condition()
+		left = &AndExpr{left, right}
 	}
 	return left
 }
 
+/*
+condition parses an atom, recovering from its errors: the error is recorded, and the rest of the condition is skipped
+up to the next "&", "|" or closing parenthesis, so that the following conditions can be checked too
+*/
+func (p *parser) condition() (res Expression) {
+	depth := p.depth
+	defer func() {
+		if r := recover(); r != nil {
+			if p.fatal {
+				panic(r)
+			}
+			p.addError(p.parseError(r))
+			for p.next.class != tkEOF {
+				if p.depth <= depth && (p.next.class == tkAnd || p.next.class == tkOr || p.next.class == tkRparen) {
+					break
+				}
+				p.advance()
+			}
+			res = nil
+		}
+	}()
+	return p.atom()
+}
+
 func (p *parser) atom() Expression {
 	switch {
 	case p.found(tkNot):
-		return &exprNot{p.atom()}
+		return &NotExpr{p.atom()}
 	case p.found(tkLparen):
 		res := p.or()
 		if !p.found(tkRparen) {
-			p.advance()
-			panic("was expecting a closing parenthesis")
+			p.unexpected()
 		}
 		return res
-	case p.found(tkLiteral):
+	case p.foundField():
 		field := p.matched.value
+		if quantifier := strings.ToLower(field); quantifiers[quantifier] && p.next.class == tkLparen {
+			return p.quantifier(quantifier)
+		}
+		if _, isFunction := functions[field]; isFunction && p.next.class == tkLparen {
+			return p.comparison(p.call(field))
+		}
 		operators, found := p.fieldOperators(field)
 		if !found {
-			panic(fmt.Sprintf("Unknown field %s", field))
+			p.unknownField(field)
+		}
+		if p.next.class == tkLiteral && strings.EqualFold(p.next.value, "is") {
+			p.advance()
+			return p.null(field)
 		}
 		if !p.found(tkCompOp) {
 			if !hasOperator(operators, IS) {
-				panic(fmt.Sprintf("field %s cannot be used without an operator", field))
+				panic(fmt.Sprintf("field %s cannot be used without an operator%s", field, supportedOperators(operators)))
 			}
-			return &exprComp{field: field}
+			return &CompExpr{Field: field}
 		}
 
 		operator := p.matched.value
-		if !hasOperator(operators, operatorMapping[operator]) {
-			panic(fmt.Sprintf("field %s doesn not support operator %s", field, operator))
+		native := operatorMapping[operator] != Operator(operator) && hasOperator(operators, Operator(operator))
+		if !native && !hasOperator(operators, operatorMapping[operator]) {
+			panic(fmt.Sprintf("field %s does not support operator %s%s", field, operator, supportedOperators(operators)))
+		}
+		left := &FieldOperand{Field: field}
+		right := p.rightSide(left, lookupType(p.types, field), operator)
+		if literal, ok := right.(*LiteralOperand); ok {
+			checkValue(left, lookupType(p.types, field), literal.Text)
+			return &CompExpr{Field: field, Operator: operator, Value: literal.Text, Derived: !native && nativeOperators[operator]}
+		}
+		return &ValueExpr{Left: left, Operator: operator, Right: right}
+	default:
+		p.unexpected()
+		return nil
+	}
+}
+
+/*
+call parses a function call, e.g. split(image, ":")[0], and returns it along with the type of its result
+*/
+func (p *parser) call(name string) (Operand, Type) {
+	function := functions[name]
+	p.expect(tkLparen)
+	arg, argType := p.argument(name)
+	if !function.accepts(argType) {
+		panic(fmt.Sprintf("%s(...) cannot be applied to the %s value %v", name, argType, arg))
+	}
+	var params []string
+	for len(params) < function.Params {
+		if !p.found(tkComma) {
+			p.advance()
+			panic(fmt.Sprintf("%s(...) was expecting %d parameters after %v", name, function.Params, arg))
 		}
 		if !p.found(tkLiteral) {
 			p.advance()
-			panic("was expecting a comparison value")
+			panic(fmt.Sprintf("was expecting a parameter in %s(...)", name))
+		}
+		params = append(params, p.matched.value)
+	}
+	if !p.found(tkRparen) {
+		p.unexpected()
+	}
+
+	var res Operand = &CallOperand{Function: name, Arg: arg, Params: params}
+	resType := function.Output
+	for p.found(tkLbracket) {
+		if resType != TypeList && resType != TypeAny {
+			panic(fmt.Sprintf("the %s value %v cannot be indexed", resType, res))
+		}
+		if !p.found(tkLiteral) {
+			p.advance()
+			panic("was expecting an index")
+		}
+		index, err := strconv.Atoi(p.matched.value)
+		if err != nil {
+			panic(fmt.Sprintf("invalid index %s", p.matched.value))
+		}
+		if !p.found(tkRbracket) {
+			p.unexpected()
+		}
+		res, resType = &IndexOperand{List: res, Index: index}, TypeString
+	}
+	return res, resType
+}
+
+// argument parses the value a function is applied to: a field or another function call
+func (p *parser) argument(name string) (Operand, Type) {
+	if !p.foundField() {
+		p.advance()
+		panic(fmt.Sprintf("was expecting a field name in %s(...)", name))
+	}
+	field := p.matched.value
+	if _, isFunction := functions[field]; isFunction && p.next.class == tkLparen {
+		return p.call(field)
+	}
+	if _, found := p.fieldOperators(field); !found {
+		p.unknownField(field)
+	}
+	return &FieldOperand{Field: field}, lookupType(p.types, field)
+}
+
+/*
+rightSide parses the right side of a comparison: a literal, a $field reference or an arithmetic expression of them,
+e.g. "$created - 1h", whose type must be compatible with the left side
+*/
+func (p *parser) rightSide(left Operand, t Type, operator string) Operand {
+	p.left, p.operator = left, operator
+	defer func() { p.left, p.operator = nil, "" }()
+	start := p.next.pos
+	right, rightType := p.sum()
+	switch right.(type) {
+	case *LiteralOperand:
+		return right
+	case *ArithOperand:
+		if operatorMapping[operator] == LIKE {
+			panic(posError{pos: start, message: fmt.Sprintf("operator %s cannot be used with an arithmetic expression", operator)})
+		}
+	}
+	if !compatible(t, rightType) {
+		panic(posError{pos: start, message: fmt.Sprintf("%v (%s) cannot be compared to %v (%s)", left, t, right, rightType)})
+	}
+	return right
+}
+
+// sum parses additions and subtractions, e.g. "$created - 1h"
+func (p *parser) sum() (Operand, Type) {
+	left, t := p.product()
+	for p.next.class == tkArith && (p.next.value == "+" || p.next.value == "-") {
+		p.advance()
+		left, t = p.arith(left, t, p.matched, p.product)
+	}
+	return left, t
+}
+
+// product parses multiplications and divisions, e.g. "2 * 500MB"
+func (p *parser) product() (Operand, Type) {
+	left, t := p.factor()
+	for p.next.class == tkArith && (p.next.value == "*" || p.next.value == "/") {
+		p.advance()
+		left, t = p.arith(left, t, p.matched, p.factor)
+	}
+	return left, t
+}
+
+// arith parses the right operand of an arithmetic operator, and checks the operation is supported by the operand types
+func (p *parser) arith(left Operand, leftType Type, operator token, next func() (Operand, Type)) (Operand, Type) {
+	right, rightType := next()
+	left, leftType = p.arithOperand(left, leftType)
+	right, rightType = p.arithOperand(right, rightType)
+	t, ok := arithType(operator.value, leftType, rightType)
+	if !ok {
+		panic(posError{pos: operator.pos, message: fmt.Sprintf("cannot compute %v %s %v: operator %s is not supported between %s and %s",
+			left, operator.value, right, operator.value, leftType, rightType)})
+	}
+	return &ArithOperand{Operator: operator.value, Left: left, Right: right}, t
+}
+
+/*
+arithOperand returns an arithmetic operand and its type, parsing literals as numbers, durations or sizes.
+The literals naming a field are references to it, e.g. created in "exited > created - 1h" is handled as $created.
+*/
+func (p *parser) arithOperand(op Operand, t Type) (Operand, Type) {
+	literal, ok := op.(*LiteralOperand)
+	if !ok {
+		return op, t
+	}
+	_, t, err := literal.typed()
+	if err == nil {
+		return op, t
+	}
+	if _, found := p.fieldOperators(literal.Text); found && !needsQuotes(literal.Text) {
+		return &RefOperand{FieldOperand{Field: literal.Text}}, lookupType(p.types, literal.Text)
+	}
+	panic(err.Error() + didYouMean("$", suggestField(p.fields, literal.Text)))
+}
+
+// factor parses an operand of an arithmetic expression: a literal or a $field reference
+func (p *parser) factor() (Operand, Type) {
+	switch {
+	case p.found(tkRef):
+		field := p.matched.value
+		if _, found := p.fieldOperators(field); !found {
+			panic(fmt.Sprintf("Unknown field or variable $%s%s", field, didYouMean("$", suggestField(p.fields, field))))
+		}
+		return &RefOperand{FieldOperand{Field: field}}, lookupType(p.types, field)
+	case p.found(tkLiteral):
+		return &LiteralOperand{Text: p.matched.value}, TypeAny
+	default:
+		p.unexpected()
+		return nil, TypeAny
+	}
+}
+
+/*
+compatible returns true if values of the provided types can be compared, lists being compared through their values,
+and sizes to numbers of bytes
+*/
+func compatible(t, other Type) bool {
+	switch {
+	case t == TypeAny || other == TypeAny || t == other:
+		return true
+	case t == TypeString || t == TypeList:
+		return other == TypeString || other == TypeList
+	case t == TypeInt || t == TypeSize:
+		return other == TypeInt || other == TypeSize
+	default:
+		return false
+	}
+}
+
+// typeOperators are the operators supported by the values computed by functions, depending on their type
+var typeOperators = map[Type][]Operator{
+	TypeAny:      {IS, EQ, LIKE, GT},
+	TypeBool:     {IS},
+	TypeString:   {EQ, LIKE},
+	TypeList:     {EQ, LIKE},
+	TypeInt:      {EQ, GT},
+	TypeSize:     {EQ, GT},
+	TypeDuration: {EQ, GT},
+}
+
+// comparison parses the comparison of a value computed by functions, whose operator and value depend on the value type
+func (p *parser) comparison(left Operand, t Type) Expression {
+	operators := typeOperators[t]
+	if !p.found(tkCompOp) {
+		if !hasOperator(operators, IS) {
+			panic(fmt.Sprintf("%v cannot be used without an operator%s", left, supportedOperators(operators)))
+		}
+		return &ValueExpr{Left: left}
+	}
+
+	operator := p.matched.value
+	if !hasOperator(operators, operatorMapping[operator]) {
+		panic(fmt.Sprintf("%v does not support operator %s%s", left, operator, supportedOperators(operators)))
+	}
+	right := p.rightSide(left, t, operator)
+	literal, ok := right.(*LiteralOperand)
+	if !ok {
+		return &ValueExpr{Left: left, Operator: operator, Right: right}
+	}
+	checkValue(left, t, literal.Text)
+	return &ValueExpr{Left: left, Operator: operator, Value: literal.Text}
+}
+
+/*
+checkValue checks that a value can be compared to a value of the provided type, e.g. that exit is compared to an integer,
+instead of failing when the query is matched. Empty values, e.g. exit!="", are rejected too.
+*/
+func checkValue(left Operand, t Type, value string) {
+	var message string
+	switch t {
+	case TypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			message = fmt.Sprintf("%v should be compared to an integer", left)
+		}
+	case TypeDuration:
+		if _, err := ParseDuration(value); err != nil {
+			message = fmt.Sprintf("%v should be compared to a duration, e.g. 2w", left)
+		}
+	case TypeSize:
+		if _, err := ParseSize(value); err != nil {
+			message = fmt.Sprintf("%v should be compared to a size, e.g. 200MB", left)
+		}
+	}
+	if len(message) == 0 {
+		return
+	}
+	if strings.ContainsAny(value, "+-*/") {
+		// e.g. size>2*500MB, which is lexed as a single value to leave values like library/redis-3 intact
+		message += ", and arithmetic operators must be surrounded by spaces"
+	}
+	panic(message)
+}
+
+// null parses the end of a null test, e.g. exit is null or exit is not null
+func (p *parser) null(field string) Expression {
+	negated := p.found(tkNot)
+	if !p.found(tkLiteral) {
+		p.advance()
+		panic("was expecting null")
+	}
+	if !strings.EqualFold(p.matched.value, "null") {
+		panic("was expecting null")
+	}
+	return &NullExpr{Field: field, Negated: negated}
+}
+
+var quantifiers = map[string]bool{
+	quantAny:  true,
+	quantAll:  true,
+	quantNone: true,
+}
+
+func (p *parser) quantifier(quantifier string) Expression {
+	p.expect(tkLparen)
+	errors := len(p.errors)
+	expression := p.or()
+	if !p.found(tkRparen) {
+		p.unexpected()
+	}
+	if len(p.errors) > errors {
+		// the fields of an invalid expression are unreliable
+		return nil
+	}
+	return p.quantified(quantifier, expression)
+}
+
+// quantified checks that a quantified expression references a single field, and returns the quantifier expression
+func (p *parser) quantified(quantifier string, expression Expression) Expression {
+	fields := map[string]bool{}
+	if !quantifiedFields(expression, fields) {
+		panic(fmt.Sprintf("%s(...) cannot be nested in another quantifier", quantifier))
+	}
+	if len(fields) != 1 {
+		panic(fmt.Sprintf("%s(...) should reference a single field", quantifier))
+	}
+	res := &QuantExpr{Quantifier: quantifier, Expression: expression}
+	for field := range fields {
+		res.Field = field
+	}
+	return res
+}
+
+// quantifiedFields collects the fields referenced by a quantified expression, and returns false if it contains another quantifier
+func quantifiedFields(expression Expression, fields map[string]bool) bool {
+	switch e := expression.(type) {
+	case *OrExpr:
+		return quantifiedFields(e.Left, fields) && quantifiedFields(e.Right, fields)
+	case *AndExpr:
+		return quantifiedFields(e.Left, fields) && quantifiedFields(e.Right, fields)
+	case *NotExpr:
+		return quantifiedFields(e.Expression, fields)
+	case *CompExpr:
+		fields[e.Field] = true
+		return true
+	case *NullExpr:
+		fields[e.Field] = true
+		return true
+	case *ValueExpr:
+		operandFields(e.Left, fields)
+		if e.Right != nil {
+			operandFields(e.Right, fields)
 		}
-		value := p.matched.value
-		return &exprComp{field: field, operator: operator, value: value}
-	case p.found(tkEOF):
-		panic("Unexpected end of query")
+		return true
 	default:
-		panic("unexpected input")
+		return false
+	}
+}
+
+// operandFields collects the fields referenced by an operand
+func operandFields(op Operand, fields map[string]bool) {
+	switch o := op.(type) {
+	case *FieldOperand:
+		fields[o.Field] = true
+	case *CallOperand:
+		operandFields(o.Arg, fields)
+	case *IndexOperand:
+		operandFields(o.List, fields)
+	case *RefOperand:
+		fields[o.Field] = true
+	case *ArithOperand:
+		operandFields(o.Left, fields)
+		operandFields(o.Right, fields)
 	}
 }
 
 func (p *parser) fieldOperators(field string) ([]Operator, bool) {
-	operators, found := p.fields[field]
+	return LookupField(p.fields, field)
+}
+
+/*
+LookupField returns the operators supported by a field, taking wildcard fields like "label.*" into account.
+The second return value is false if the field is unknown.
+*/
+func LookupField(fields map[string][]Operator, field string) ([]Operator, bool) {
+	operators, found := fields[field]
 	if found {
 		return operators, true
 	}
-	for k, v := range p.fields {
-		if strings.HasSuffix(k, ".*") && strings.HasPrefix(field, strings.TrimSuffix(k, ".*")) {
+	for k, v := range fields {
+		if matchesWildcard(k, field) {
 			return v, true
 		}
 	}
@@ -132,6 +640,13 @@ func (p *parser) fieldOperators(field string) ([]Operator, bool) {
 	return nil, false
 }
 
+// nativeOperators are the comparison operators queryables answer themselves for the fields declaring them
+var nativeOperators = map[string]bool{NE: true, GE: true, LT: true, LE: true}
+
+/*
+operatorMapping maps the comparison operators to the queryable operators they are derived from,
+for the fields which do not declare the NE, LT, GE and LE operators
+*/
 var operatorMapping = map[string]Operator{
 	"=":  EQ,
 	"!=": EQ,
@@ -154,20 +669,113 @@ func hasOperator(operators []Operator, op Operator) bool {
 
 func (p *parser) expect(class tokenClass) {
 	if !p.found(class) {
-		panic(fmt.Sprintf("was expecting %v", class))
+		p.unexpected()
 	}
 }
 
+// found matches the next token if it is of the provided class, or else records that the class was expected
 func (p *parser) found(class tokenClass) bool {
+	return p.foundAs(class, class.description())
+}
+
+// foundAs is found, with the description of the expected token when it depends on the context, e.g. a field for literals
+func (p *parser) foundAs(class tokenClass, description string) bool {
 	if p.next.class == class {
-		p.matched = p.next
-		p.next = p.lexer.next()
+		p.advance()
 		return true
 	}
+	if p.completing() {
+		p.completion.expect(p, class)
+	}
+	for _, expected := range p.expected {
+		if expected == description {
+			return false
+		}
+	}
+	p.expected = append(p.expected, description)
 	return false
 }
 
+// foundField matches a literal expected as a field name, which the completion reports in Completion.Fields
+func (p *parser) foundField() bool {
+	if p.completing() {
+		p.completion.Fields = true
+	}
+	return p.foundAs(tkLiteral, "a field")
+}
+
+// completing is true if the end of a partial query being completed is reached, before any error
+func (p *parser) completing() bool {
+	return p.completion != nil && p.next.class == tkEOF && !p.completed
+}
+
+/*
+scan returns the next token, replacing the $name references to variables or environment variables with literal tokens
+holding their values
+*/
+func (p *parser) scan() token {
+	defer func() {
+		if r := recover(); r != nil {
+			p.fatal = true
+			panic(r)
+		}
+	}()
+	res := p.lexer.next()
+	if res.class != tkRef {
+		return res
+	}
+	if value, found := p.variables[res.value]; found {
+		return token{class: tkLiteral, value: value, pos: res.pos}
+	}
+	if _, found := p.fieldOperators(res.value); found {
+		return res
+	}
+	if p.environment != nil {
+		if value, found := p.environment(res.value); found {
+			return token{class: tkLiteral, value: value, pos: res.pos}
+		}
+	}
+	return res
+}
+
+// syntaxError is raised when the next token is none of the expected ones
+type syntaxError struct {
+	pos      int
+	message  string
+	expected []string
+}
+
+/*
+unexpected fails on the next token, reporting the tokens which were expected instead.
+The token is not consumed, so that the parsing can resume from it, e.g. from a closing parenthesis.
+*/
+func (p *parser) unexpected() {
+	message := "Unexpected end of query"
+	if p.next.class != tkEOF {
+		message = fmt.Sprintf("Unexpected %s", p.next.description())
+	}
+	if len(p.expected) != 0 {
+		message += ", was expecting " + enumerate(p.expected, "or")
+	}
+	panic(syntaxError{pos: p.next.pos, message: message, expected: p.expected})
+}
+
+// unknownField fails on an unknown field, suggesting the closest field, or the closest function if it is followed by a parenthesis
+func (p *parser) unknownField(field string) {
+	if p.next.class == tkLparen {
+		panic(fmt.Sprintf("Unknown function %s%s", field, didYouMean("", suggestFunction(field))))
+	}
+	panic(fmt.Sprintf("Unknown field %s%s", field, didYouMean("", suggestField(p.fields, field))))
+}
+
 func (p *parser) advance() {
 	p.matched = p.next
-	p.next = p.lexer.next()
+	p.next = p.scan()
+	p.expected = nil
+	switch p.matched.class {
+	case tkLparen:
+		p.depth++
+	case tkRparen:
+		p.depth--
+	}
 }
@@ -21,13 +21,13 @@ func TestSimpleParse(t *testing.T) {
 	}{
 		{
 			input:    "running",
-			expected: &exprComp{field: "running"},
+			expected: &CompExpr{Field: "running"},
 		}, {
 			input:    "name~x",
-			expected: &exprComp{field: "name", operator: "~", value: "x"},
+			expected: &CompExpr{Field: "name", Operator: "~", Value: "x"},
 		}, {
 			input:    "name=x",
-			expected: &exprComp{field: "name", operator: "=", value: "x"},
+			expected: &CompExpr{Field: "name", Operator: "=", Value: "x"},
 		},
 	}
 
@@ -40,17 +40,17 @@ func TestSimpleParse(t *testing.T) {
 }
 
 func TestComplexParse(t *testing.T) {
-	expected := &exprOr{
-		left: &exprComp{field: "name", operator: "=", value: "jawher/image"},
-		right: &exprAnd{
-			left: &exprOr{
-				left:  &exprComp{field: "running"},
-				right: &exprComp{field: "exit", operator: "!=", value: "1"},
+	expected := &OrExpr{
+		Left: &CompExpr{Field: "name", Operator: "=", Value: "jawher/image"},
+		Right: &AndExpr{
+			Left: &OrExpr{
+				Left:  &CompExpr{Field: "running"},
+				Right: &CompExpr{Field: "exit", Operator: "!=", Value: "1", Derived: true},
 			},
-			right: &exprNot{
-				&exprOr{
-					left:  &exprComp{field: "name", operator: "~", value: "angry"},
-					right: &exprComp{field: "name", operator: "!~", value: "panini"},
+			Right: &NotExpr{
+				&OrExpr{
+					Left:  &CompExpr{Field: "name", Operator: "~", Value: "angry"},
+					Right: &CompExpr{Field: "name", Operator: "!~", Value: "panini"},
 				},
 			},
 		},
@@ -62,3 +62,570 @@ func TestComplexParse(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expected, ast)
 }
+
+func TestParseNamedQueries(t *testing.T) {
+	queries := map[string]string{
+		"dead":    "!running & exit!=0",
+		"crashed": "@dead & exit>128",
+		"loop":    "running | @loop2",
+		"loop2":   "@loop",
+		"invalid": "exit>",
+	}
+
+	ast, err := Parse("@crashed | @dead & name=x", fields, NamedQueries(queries))
+	require.NoError(t, err)
+
+	dead := &AndExpr{
+		Left:  &NotExpr{&CompExpr{Field: "running"}},
+		Right: &CompExpr{Field: "exit", Operator: "!=", Value: "0", Derived: true},
+	}
+	require.Equal(t, &OrExpr{
+		Left: &AndExpr{
+			Left:  dead,
+			Right: &CompExpr{Field: "exit", Operator: ">", Value: "128"},
+		},
+		Right: &AndExpr{
+			Left:  dead,
+			Right: &CompExpr{Field: "name", Operator: "=", Value: "x"},
+		},
+	}, ast)
+
+	// the same named query can be used more than once, e.g. in both operands
+	_, err = Parse("@dead | (running & @dead)", fields, NamedQueries(queries))
+	require.NoError(t, err)
+
+	errorCases := []struct {
+		input    string
+		expanded string
+		pos      int
+	}{
+		{"running & @nope", "running & @nope", 10},
+		{"running | @loop", "running | (running | (@loop))", 22},
+		{"name=x & @invalid", "name=x & (exit>)", 15},
+		{"@", "@", 0},
+	}
+	for _, cas := range errorCases {
+		_, err := Parse(cas.input, fields, NamedQueries(queries))
+		require.Error(t, err, "parsing '%s' should have failed", cas.input)
+		t.Log(err)
+		pErr := err.(ParseError)
+		require.Equal(t, cas.expanded, pErr.Input, "parsing '%s'", cas.input)
+		require.Equal(t, cas.pos, pErr.Pos, "parsing '%s'", cas.input)
+	}
+}
+
+func TestParseLexerErrors(t *testing.T) {
+	_, err := Parse(`name="abc`, fields)
+	require.Error(t, err)
+	require.Equal(t, ParseError{Input: `name="abc`, Pos: 5, Message: "unclosed string"}, err)
+
+	_, err = Parse(`"abc`, fields)
+	require.Error(t, err)
+}
+
+func TestParseKeywords(t *testing.T) {
+	symbols, err := Parse(`running & !(name~api | name="or")`, fields)
+	require.NoError(t, err)
+
+	words, err := Parse(`running and not (name~api OR name="or")`, fields)
+	require.NoError(t, err)
+	require.Equal(t, symbols, words)
+
+	_, err = Parse(`name=or`, fields)
+	require.Error(t, err)
+}
+
+func TestParseQuantifiers(t *testing.T) {
+	ast, err := Parse("running & ALL(name~registry.local | name=x) | none(exit=0)", fields)
+	require.NoError(t, err)
+	require.Equal(t, &OrExpr{
+		Left: &AndExpr{
+			Left: &CompExpr{Field: "running"},
+			Right: &QuantExpr{
+				Quantifier: quantAll,
+				Field:      "name",
+				Expression: &OrExpr{
+					Left:  &CompExpr{Field: "name", Operator: "~", Value: "registry.local"},
+					Right: &CompExpr{Field: "name", Operator: "=", Value: "x"},
+				},
+			},
+		},
+		Right: &QuantExpr{
+			Quantifier: quantNone,
+			Field:      "exit",
+			Expression: &CompExpr{Field: "exit", Operator: "=", Value: "0"},
+		},
+	}, ast)
+
+	for _, input := range []string{
+		"any(name=x & exit=0)",
+		"any(all(name=x))",
+		"any(name=x",
+		"any()",
+	} {
+		_, err := Parse(input, fields)
+		require.Error(t, err, "parsing '%s' should have failed", input)
+		t.Log(err)
+	}
+}
+
+func TestParseFunctions(t *testing.T) {
+	fields := map[string][]Operator{
+		"name":    {EQ, LIKE},
+		"image":   {EQ, LIKE},
+		"tag":     {EQ, LIKE},
+		"label.*": {IS, EQ, LIKE},
+		"exit":    {EQ, GT},
+	}
+	types := Types(map[string]Type{
+		"name":    TypeString,
+		"image":   TypeString,
+		"tag":     TypeList,
+		"label.*": TypeString,
+		"exit":    TypeInt,
+	})
+
+	ast, err := Parse("len(tag) > 3 & (count(tag)>=2 | len(label.arch)=0)", fields, types)
+	require.NoError(t, err)
+	require.Equal(t, &AndExpr{
+		Left: &ValueExpr{Left: call("len", field("tag")), Operator: ">", Value: "3"},
+		Right: &OrExpr{
+			Left:  &ValueExpr{Left: call("count", field("tag")), Operator: ">=", Value: "2"},
+			Right: &ValueExpr{Left: call("len", field("label.arch")), Operator: "=", Value: "0"},
+		},
+	}, ast)
+
+	ast, err = Parse(`lower(trim(label.env))=prod | split(image, ":")[1]=latest | basename(split(image, ",")[-1]) ~ a,b`, fields, types)
+	require.NoError(t, err)
+	require.Equal(t, &OrExpr{
+		Left: &OrExpr{
+			Left:  &ValueExpr{Left: call("lower", call("trim", field("label.env"))), Operator: "=", Value: "prod"},
+			Right: &ValueExpr{Left: &IndexOperand{List: call("split", field("image"), ":"), Index: 1}, Operator: "=", Value: "latest"},
+		},
+		Right: &ValueExpr{
+			Left:     call("basename", &IndexOperand{List: call("split", field("image"), ","), Index: -1}),
+			Operator: "~",
+			Value:    "a,b",
+		},
+	}, ast)
+
+	ast, err = Parse("any(len(tag)>20)", fields, types)
+	require.NoError(t, err)
+	require.Equal(t, &QuantExpr{
+		Quantifier: quantAny,
+		Field:      "tag",
+		Expression: &ValueExpr{Left: call("len", field("tag")), Operator: ">", Value: "20"},
+	}, ast)
+
+	// without types, the functions are checked against the values when matching
+	_, err = Parse("count(name)>1", fields)
+	require.NoError(t, err)
+
+	for _, input := range []string{
+		"len(unknown)>1",
+		"count(name)>1",
+		"len(exit)>1",
+		"lower(tag)=x",
+		"len(tag)",
+		"len(tag)~1",
+		"len(tag)>x",
+		"len(tag>1",
+		"len()>1",
+		"lower(name)>1",
+		"split(image)=x",
+		"split(image, :, x)=x",
+		"lower(name, x)=x",
+		"lower(name)[0]=x",
+		"split(image, :)[x]=y",
+		"split(image, :)[0=y",
+	} {
+		_, err := Parse(input, fields, types)
+		require.Error(t, err, "parsing '%s' should have failed", input)
+		t.Log(err)
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	fields := map[string][]Operator{
+		"name":    {EQ, LIKE},
+		"image":   {EQ, LIKE},
+		"tag":     {EQ, LIKE},
+		"label.*": {IS, EQ, LIKE},
+		"created": {EQ, GT},
+		"exited":  {EQ, GT},
+	}
+	types := Types(map[string]Type{
+		"name":    TypeString,
+		"image":   TypeString,
+		"tag":     TypeList,
+		"label.*": TypeString,
+		"created": TypeDuration,
+		"exited":  TypeDuration,
+	})
+
+	ast, err := Parse(`label.expected_image != $image & exited<$created | lower(name)=$label.name | tag="$name"`, fields, types)
+	require.NoError(t, err)
+	require.Equal(t, &OrExpr{
+		Left: &OrExpr{
+			Left: &AndExpr{
+				Left:  &ValueExpr{Left: field("label.expected_image"), Operator: "!=", Right: &RefOperand{FieldOperand{Field: "image"}}},
+				Right: &ValueExpr{Left: field("exited"), Operator: "<", Right: &RefOperand{FieldOperand{Field: "created"}}},
+			},
+			Right: &ValueExpr{Left: call("lower", field("name")), Operator: "=", Right: &RefOperand{FieldOperand{Field: "label.name"}}},
+		},
+		Right: &CompExpr{Field: "tag", Operator: "=", Value: "$name"},
+	}, ast)
+
+	_, err = Parse("tag=$name", fields, types)
+	require.NoError(t, err)
+
+	for _, input := range []string{
+		"name=$unknown",
+		"name=$created",
+		"len(name)=$name",
+		"created>$",
+		"any(tag=$name)",
+	} {
+		_, err := Parse(input, fields, types)
+		require.Error(t, err, "parsing '%s' should have failed", input)
+		t.Log(err)
+	}
+}
+
+func TestParseArithmetic(t *testing.T) {
+	fields := map[string][]Operator{
+		"name":    {EQ, LIKE},
+		"size":    {EQ, GT},
+		"exit":    {EQ, GT},
+		"created": {EQ, GT},
+		"exited":  {EQ, GT},
+	}
+	types := Types(map[string]Type{
+		"name":    TypeString,
+		"size":    TypeSize,
+		"exit":    TypeInt,
+		"created": TypeDuration,
+		"exited":  TypeDuration,
+	})
+
+	ast, err := Parse("exited > $created - 1h & size > 100MB + 2 * 500MB | name=library/redis-3", fields, types)
+	require.NoError(t, err)
+	require.Equal(t, &OrExpr{
+		Left: &AndExpr{
+			Left: &ValueExpr{
+				Left:     field("exited"),
+				Operator: ">",
+				Right: &ArithOperand{
+					Operator: "-",
+					Left:     &RefOperand{FieldOperand{Field: "created"}},
+					Right:    literal("1h"),
+				},
+			},
+			Right: &ValueExpr{
+				Left:     field("size"),
+				Operator: ">",
+				Right: &ArithOperand{
+					Operator: "+",
+					Left:     literal("100MB"),
+					Right:    &ArithOperand{Operator: "*", Left: literal("2"), Right: literal("500MB")},
+				},
+			},
+		},
+		Right: &CompExpr{Field: "name", Operator: "=", Value: "library/redis-3"},
+	}, ast)
+
+	ast, err = Parse("len(name) > 2 * 10", fields, types)
+	require.NoError(t, err)
+	require.Equal(t, &ValueExpr{
+		Left:     call("len", field("name")),
+		Operator: ">",
+		Right:    &ArithOperand{Operator: "*", Left: literal("2"), Right: literal("10")},
+	}, ast)
+
+	// the field names are references to the fields in arithmetic expressions
+	ast, err = Parse("exited > created - 1h", fields, types)
+	require.NoError(t, err)
+	expected, err := Parse("exited > $created - 1h", fields, types)
+	require.NoError(t, err)
+	require.Equal(t, expected, ast)
+
+	for query, message := range map[string]string{
+		"exited > creatd - 1h": "creatd is neither a number, a duration nor a size, did you mean $created?",
+		"size>2*500MB":         "size should be compared to a size, e.g. 200MB, and arithmetic operators must be surrounded by spaces",
+		"exit>1+1":             "exit should be compared to an integer, and arithmetic operators must be surrounded by spaces",
+	} {
+		_, err := Parse(query, fields, types)
+		require.Error(t, err, "parsing '%s' should have failed", query)
+		require.Equal(t, message, err.(ParseError).Message, "parsing '%s'", query)
+	}
+
+	for _, input := range []struct {
+		query string
+		pos   int
+	}{
+		{"exited > $created + 2", 18},
+		{"exited > $created + 2MB", 18},
+		{"size > 2 * $created", 7},
+		{"size > 1h + 1h", 7},
+		{"exit > $name - 1", 13},
+		{"name ~ 1 + 1", 7},
+		{"size > 1 + abc", 11},
+		{"size > 1 +", 10},
+	} {
+		_, err := Parse(input.query, fields, types)
+		require.Error(t, err, "parsing '%s' should have failed", input.query)
+		require.Equal(t, input.pos, err.(ParseError).Pos, "error position of '%s': %v", input.query, err)
+		t.Log(err)
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	fields := map[string][]Operator{
+		"name":    {EQ, LIKE},
+		"image":   {EQ, LIKE},
+		"created": {EQ, GT},
+	}
+	variables := Variables(map[string]string{
+		"APP":     "web | x & !y",
+		"MAX_AGE": "2w",
+		"image":   "nginx",
+	})
+	environment := Environment(func(name string) (string, bool) {
+		switch name {
+		case "HOME":
+			return "/root", true
+		case "name":
+			return "from-env", true
+		}
+		return "", false
+	})
+
+	ast, err := Parse("name=$APP & created > $MAX_AGE & name~$image & image=$name | name=$HOME", fields, variables, environment)
+	require.NoError(t, err)
+	require.Equal(t, &OrExpr{
+		Left: &AndExpr{
+			Left: &AndExpr{
+				Left: &AndExpr{
+					Left:  &CompExpr{Field: "name", Operator: "=", Value: "web | x & !y"},
+					Right: &CompExpr{Field: "created", Operator: ">", Value: "2w"},
+				},
+				Right: &CompExpr{Field: "name", Operator: "~", Value: "nginx"},
+			},
+			Right: &ValueExpr{Left: field("image"), Operator: "=", Right: &RefOperand{FieldOperand{Field: "name"}}},
+		},
+		Right: &CompExpr{Field: "name", Operator: "=", Value: "/root"},
+	}, ast)
+
+	_, err = Parse("name=$MISSING", fields, variables, environment)
+	require.Error(t, err)
+	require.Equal(t, 5, err.(ParseError).Pos)
+}
+
+func TestParseMultiLine(t *testing.T) {
+	ast, err := Parse(`
+# stopped for a while
+!running
+	& exit=0 # clean exits only
+`, fields)
+	require.NoError(t, err)
+	require.Equal(t, &AndExpr{
+		Left:  &NotExpr{&CompExpr{Field: "running"}},
+		Right: &CompExpr{Field: "exit", Operator: "=", Value: "0"},
+	}, ast)
+
+	_, err = Parse("!running\n\t& unknown=0\n& exit=0", fields)
+	require.Error(t, err)
+	require.Equal(t, "Parse error at line 2: Unknown field unknown\n\t& unknown=0\n\t  ^", err.Error())
+
+	_, err = Parse("!running\n\t& \n\n", fields)
+	require.Equal(t, "Parse error at line 2: Unexpected end of query, was expecting \"!\", \"(\" or a field\n\t& \n\t ^", err.Error())
+
+	_, err = Parse("!running &", fields)
+	require.Equal(t, "Parse error: Unexpected end of query, was expecting \"!\", \"(\" or a field\n!running &\n          ^", err.Error())
+}
+
+func TestParseNull(t *testing.T) {
+	ast, err := Parse("exit is null | exit IS NOT Null & !(name is null)", fields)
+	require.NoError(t, err)
+	require.Equal(t, &OrExpr{
+		Left: &NullExpr{Field: "exit"},
+		Right: &AndExpr{
+			Left:  &NullExpr{Field: "exit", Negated: true},
+			Right: &NotExpr{&NullExpr{Field: "name"}},
+		},
+	}, ast)
+
+	ast, err = Parse("any(name is not null)", fields)
+	require.NoError(t, err)
+	require.Equal(t, &QuantExpr{Quantifier: quantAny, Field: "name", Expression: &NullExpr{Field: "name", Negated: true}}, ast)
+
+	for _, input := range []string{
+		"exit is foo",
+		"exit is not",
+		"exit is",
+	} {
+		_, err := Parse(input, fields)
+		require.Error(t, err, "parsing '%s' should have failed", input)
+		t.Log(err)
+	}
+}
+
+func TestParseNativeOperators(t *testing.T) {
+	fields := map[string][]Operator{
+		"exit":    {EQ, NE, GT, GE, LT, LE},
+		"created": {EQ, GT},
+		"size":    {LT},
+		"name":    {EQ, LIKE},
+	}
+
+	ast, err := Parse("exit<1 & exit!=2 & created<1h & size<1MB & name!~x", fields)
+	require.NoError(t, err)
+	require.Equal(t, &AndExpr{
+		Left: &AndExpr{
+			Left: &AndExpr{
+				Left: &AndExpr{
+					Left:  &CompExpr{Field: "exit", Operator: "<", Value: "1"},
+					Right: &CompExpr{Field: "exit", Operator: "!=", Value: "2"},
+				},
+				// fields only declaring EQ and GT keep deriving the other operators
+				Right: &CompExpr{Field: "created", Operator: "<", Value: "1h", Derived: true},
+			},
+			Right: &CompExpr{Field: "size", Operator: "<", Value: "1MB"},
+		},
+		Right: &CompExpr{Field: "name", Operator: "!~", Value: "x"},
+	}, ast)
+
+	// LE can neither be answered nor derived by a field only declaring LT
+	_, err = Parse("size<=1MB", fields)
+	require.Error(t, err)
+}
+
+func TestParseErrorMessages(t *testing.T) {
+	fields := map[string][]Operator{
+		"running":       {IS},
+		"name":          {EQ, LIKE},
+		"exit":          {EQ, GT},
+		"label.*":       {IS, EQ, LIKE},
+		"image.label.*": {IS, EQ},
+	}
+	cases := []struct {
+		query    string
+		message  string
+		expected []string
+	}{
+		{"nmae=x", "Unknown field nmae, did you mean name?", nil},
+		{"lable.arch=amd64", "Unknown field lable.arch, did you mean label.arch?", nil},
+		{"image.lable.maintainer", "Unknown field image.lable.maintainer, did you mean image.label.maintainer?", nil},
+		{"unknown=0", "Unknown field unknown", nil},
+		{"exit>$nmae", "Unknown field or variable $nmae, did you mean $name?", nil},
+		{"lenn(name)>3", "Unknown function lenn, did you mean len?", nil},
+		{"name>3", "field name does not support operator >, supported operators: =, !=, ~, !~", nil},
+		{"running=1", "field running does not support operator =, it can only be used without an operator", nil},
+		{"exit", "field exit cannot be used without an operator, supported operators: =, !=, >, >=, <, <=", nil},
+		{"len(name)~3", "len(name) does not support operator ~, supported operators: =, !=, >, >=, <, <=", nil},
+		{"running x", `Unexpected "x", was expecting an operator, "&", "|" or the end of the query`, []string{"an operator", `"&"`, `"|"`, "the end of the query"}},
+		{"(running", `Unexpected end of query, was expecting an operator, "&", "|" or ")"`, []string{"an operator", `"&"`, `"|"`, `")"`}},
+		{"exit=", "Unexpected end of query, was expecting a $field or a value", []string{"a $field", "a value"}},
+		{"running | )", `Unexpected ")", was expecting "!", "(" or a field`, []string{`"!"`, `"("`, "a field"}},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.query, fields)
+		require.Error(t, err, "parsing '%s' should have failed", c.query)
+		pErr := err.(ParseError)
+		require.Equal(t, c.message, pErr.Message, "parsing '%s'", c.query)
+		require.Equal(t, c.expected, pErr.Expected, "parsing '%s'", c.query)
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	fields := map[string][]Operator{
+		"running": {IS},
+		"name":    {EQ, LIKE},
+		"exit":    {EQ, GT},
+		"size":    {EQ, GT},
+		"tag":     {EQ, LIKE},
+	}
+	types := Types(map[string]Type{"exit": TypeInt, "size": TypeSize, "tag": TypeList})
+
+	_, err := Parse("nmae=x & exitt>0 & size>abc", fields, types)
+	require.IsType(t, ParseErrors{}, err)
+	require.Equal(t, ParseErrors{
+		{Input: "nmae=x & exitt>0 & size>abc", Pos: 0, Message: "Unknown field nmae, did you mean name?"},
+		{Input: "nmae=x & exitt>0 & size>abc", Pos: 9, Message: "Unknown field exitt, did you mean exit?"},
+		{Input: "nmae=x & exitt>0 & size>abc", Pos: 24, Message: "size should be compared to a size, e.g. 200MB"},
+	}, err)
+	require.Equal(t, `Parse error: Unknown field nmae, did you mean name?
+nmae=x & exitt>0 & size>abc
+^
+Parse error: Unknown field exitt, did you mean exit?
+nmae=x & exitt>0 & size>abc
+         ^
+Parse error: size should be compared to a size, e.g. 200MB
+nmae=x & exitt>0 & size>abc
+                        ^`, err.Error())
+
+	for _, input := range []struct {
+		query     string
+		positions []int
+	}{
+		// the parsing resumes after the closing parenthesis of an invalid group
+		{"(nmae=x | exit>) & running=1", []int{1, 15, 26}},
+		{"!(nmae=x | (exitt>1 & running)) | size>", []int{2, 12, 39}},
+		// the unexpected tokens are only reported once
+		{"running & & exit=0 & nmae=1", []int{10, 21}},
+		{"running | )", []int{10}},
+		// the fields of the invalid quantified expressions are not checked
+		{"all(tagg=x) & any(tag=x & name=y) & nmae", []int{4, 32, 36}},
+		{"len(nmae)>3 & split(name)[0]=x & exit>1h", []int{4, 24, 38}},
+		// the lexer errors stop the parsing
+		{`exitt=0 & name="abc & nmae=1`, []int{0, 15}},
+	} {
+		_, err := Parse(input.query, fields, types)
+		require.Error(t, err, "parsing '%s' should have failed", input.query)
+		var positions []int
+		switch e := err.(type) {
+		case ParseErrors:
+			for _, pErr := range e {
+				positions = append(positions, pErr.Pos)
+			}
+		case ParseError:
+			positions = append(positions, e.Pos)
+		}
+		require.Equal(t, input.positions, positions, "error positions of '%s': %v", input.query, err)
+	}
+}
+
+func TestParseValueTypes(t *testing.T) {
+	fields := map[string][]Operator{
+		"name":    {EQ, LIKE},
+		"exit":    {EQ, GT},
+		"size":    {EQ, GT},
+		"created": {EQ, GT},
+	}
+	types := Types(map[string]Type{"exit": TypeInt, "size": TypeSize, "created": TypeDuration})
+
+	for _, query := range []string{`exit>-1`, `size>=200MB`, `created<1w2d`, `name=""`, `len(name)>3`} {
+		_, err := Parse(query, fields, types)
+		require.NoError(t, err, "parsing '%s'", query)
+	}
+
+	for query, message := range map[string]string{
+		`exit>abc`:     "exit should be compared to an integer",
+		`exit!=""`:     "exit should be compared to an integer",
+		`size>abc`:     "size should be compared to a size, e.g. 200MB",
+		`created>xyz`:  "created should be compared to a duration, e.g. 2w",
+		`len(name)>ab`: "len(name) should be compared to an integer",
+	} {
+		_, err := Parse(query, fields, types)
+		require.IsType(t, ParseError{}, err, "parsing '%s'", query)
+		require.Equal(t, message, err.(ParseError).Message, "parsing '%s'", query)
+	}
+
+	// each invalid value is reported
+	_, err := Parse("exit>abc | size>abc & created>xyz", fields, types)
+	require.Equal(t, ParseErrors{
+		{Input: "exit>abc | size>abc & created>xyz", Pos: 5, Message: "exit should be compared to an integer"},
+		{Input: "exit>abc | size>abc & created>xyz", Pos: 16, Message: "size should be compared to a size, e.g. 200MB"},
+		{Input: "exit>abc | size>abc & created>xyz", Pos: 30, Message: "created should be compared to a duration, e.g. 2w"},
+	}, err)
+}
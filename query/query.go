@@ -7,6 +7,10 @@ const (
 	EQ            = "="
 	LIKE          = "~"
 	GT            = ">"
+	NE            = "!="
+	LT            = "<"
+	GE            = ">="
+	LE            = "<="
 )
 
 /*
@@ -15,6 +19,21 @@ Implementing this interface's methods for a user type makes it compatible with t
 predicates
 */
 type Queryable interface {
-	// Returns true if the provided field is set
+	/*
+		Returns true if the provided field is set, or compares to the provided value using the operator.
+		The NE, LT, GE and LE operators are only used for the fields declaring them in the fields map passed to Parse,
+		and derived from EQ and GT for the others, so that implementations only handling EQ and GT keep working.
+		Answering them directly saves an Is call and avoids matching fields without value, e.g. exit<1 on a container which never ran.
+	*/
 	Is(field string, operator Operator, value string) bool
 }
+
+/*
+SliceQueryable can be implemented by queryables having slice (multi-valued) fields to support the any, all and none quantifiers.
+Elements returns a queryable per element of the provided field, each one answering Is for that field against that single element.
+Queryables not implementing this interface are considered to have single-valued fields only.
+*/
+type SliceQueryable interface {
+	Queryable
+	Elements(field string) []Queryable
+}
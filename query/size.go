@@ -1,4 +1,4 @@
-package main
+package query
 
 import (
 	"fmt"
@@ -6,12 +6,16 @@ import (
 	"strings"
 )
 
-func parseSize(input string) (int64, error) {
-	return (&sizeParser{&parser{input: input}}).parse()
+/*
+ParseSize parses a size in bytes made of one or more numbers followed by an optional unit, e.g. "200MB", "1GB 512MB".
+KB, MB and GB are binary units while kb (or Kb), Mb and Gb are decimal ones.
+*/
+func ParseSize(input string) (int64, error) {
+	return (&sizeParser{&unitParser{input: input}}).parse()
 }
 
 type sizeParser struct {
-	*parser
+	*unitParser
 }
 
 func (p *sizeParser) parse() (res int64, err error) {
@@ -80,3 +84,16 @@ func (p *sizeParser) parseUnit() int64 {
 	}
 	panic("was expecting a size unit")
 }
+
+var sizeFormatUnits = []string{"GB", "MB", "KB"}
+
+/*
+FormatSize renders a size using its two most significant binary units, e.g. "1GB 250MB".
+The result is a valid input for ParseSize.
+*/
+func FormatSize(size int64) string {
+	if size < 1024 {
+		return strconv.FormatInt(size, 10)
+	}
+	return formatUnits(size, sizeFormatUnits, sizeUnitMultipliers)
+}
@@ -1,4 +1,4 @@
-package main
+package query
 
 import (
 	"testing"
@@ -39,7 +39,7 @@ func TestParseSize(t *testing.T) {
 	}
 
 	for _, cas := range cases {
-		size, err := parseSize(cas.input)
+		size, err := ParseSize(cas.input)
 		if cas.ko {
 			t.Log(err)
 			require.Error(t, err, "parsing '%s' should have failed", cas.input)
@@ -49,3 +49,25 @@ func TestParseSize(t *testing.T) {
 		require.Equal(t, cas.ok, size, "input '%s' should parse to %v, instead got %v", cas.input, cas.ok, size)
 	}
 }
+
+func TestFormatSize(t *testing.T) {
+	cases := []struct {
+		input    int64
+		expected string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{42 * 1024, "42KB"},
+		{42*1024 + 12, "42KB"},
+		{42 * 1024 * 1024, "42MB"},
+		{1024*1024*1024 + 250*1024*1024 + 14*1024, "1GB 250MB"},
+	}
+
+	for _, cas := range cases {
+		formatted := FormatSize(cas.input)
+		require.Equal(t, cas.expected, formatted, "size %d should be formatted as '%s'", cas.input, cas.expected)
+
+		_, err := ParseSize(formatted)
+		require.NoError(t, err, "formatted size '%s' should be parseable", formatted)
+	}
+}
@@ -0,0 +1,174 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ToSQL translates a query to a PostgreSQL WHERE clause, whose values are passed as $1, $2, ... parameters.
+
+The columns map the query fields to SQL column expressions, with the same keys as the fields map.
+The mappings of wildcard fields contain a %s, replaced by the parameter holding the rest of the field name,
+e.g. "label.*": "labels->>%s" translates label.env=prod to labels->>$1 = $2.
+The optional types are used to translate multi-valued fields, which are PostgreSQL arrays, and durations,
+which are timestamp columns compared as ages, like in created>2w.
+
+SQL NULLs follow the same three-valued logic as missing values.
+Function calls, arithmetic and like comparisons between fields cannot be translated, and return a TranslateError.
+*/
+func ToSQL(expr Expression, columns map[string]string, types map[string]Type) (where string, args []interface{}, err error) {
+	defer recoverTranslation(&err)
+	t := &sqlTranslator{columns: columns, types: types}
+	where = t.translate(expr)
+	return where, t.args, nil
+}
+
+// sqlOperators are the SQL equivalents of the comparison operators
+var sqlOperators = map[string]string{
+	"=":  "=",
+	"!=": "<>",
+	"~":  "ILIKE",
+	"!~": "NOT ILIKE",
+	">":  ">",
+	">=": ">=",
+	"<":  "<",
+	"<=": "<=",
+}
+
+type sqlTranslator struct {
+	columns map[string]string
+	types   map[string]Type
+	args    []interface{}
+	// quantified is the field whose elements are being filtered by a quantifier, and referenced as elem
+	quantified string
+}
+
+// param adds a parameter and returns its placeholder
+func (t *sqlTranslator) param(value interface{}) string {
+	t.args = append(t.args, value)
+	return fmt.Sprintf("$%d", len(t.args))
+}
+
+// column returns the column of a field, and its type
+func (t *sqlTranslator) column(expr Expression, field string) (string, Type) {
+	if field == t.quantified {
+		return "elem", TypeString
+	}
+	column := translateField(t.columns, expr, field, func(key string) string {
+		return t.param(key)
+	})
+	return column, lookupType(t.types, field)
+}
+
+func (t *sqlTranslator) translate(expr Expression) string {
+	switch e := expr.(type) {
+	case *OrExpr:
+		return fmt.Sprintf("(%s OR %s)", t.translate(e.Left), t.translate(e.Right))
+	case *AndExpr:
+		return fmt.Sprintf("(%s AND %s)", t.translate(e.Left), t.translate(e.Right))
+	case *NotExpr:
+		return fmt.Sprintf("NOT (%s)", t.translate(e.Expression))
+	case *CompExpr:
+		return t.comparison(e)
+	case *NullExpr:
+		column, _ := t.column(e, e.Field)
+		if e.Negated {
+			return column + " IS NOT NULL"
+		}
+		return column + " IS NULL"
+	case *QuantExpr:
+		return t.quantifier(e)
+	case *ValueExpr:
+		return t.fieldComparison(e)
+	default:
+		untranslatable(expr, "unsupported expression %T", expr)
+		return ""
+	}
+}
+
+func (t *sqlTranslator) comparison(e *CompExpr) string {
+	column, typ := t.column(e, e.Field)
+	if len(e.Operator) == 0 {
+		if typ == TypeBool || typ == TypeAny {
+			return column + " IS TRUE"
+		}
+		return column + " IS NOT NULL"
+	}
+
+	value, operator := translateValue(typ, e.Operator, e.Value)
+	if operator == "~" || operator == "!~" {
+		value = "%" + escapeLike(e.Value) + "%"
+	}
+	if typ != TypeList {
+		return fmt.Sprintf("%s %s %s", column, sqlOperators[operator], t.param(value))
+	}
+
+	// a multi-valued field matches if any of its values match
+	switch operator {
+	case "=":
+		return fmt.Sprintf("%s = ANY(%s)", t.param(value), column)
+	case "!=":
+		return fmt.Sprintf("NOT (%s = ANY(%s))", t.param(value), column)
+	case "~":
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(%s) AS elem WHERE elem ILIKE %s)", column, t.param(value))
+	case "!~":
+		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM unnest(%s) AS elem WHERE elem ILIKE %s)", column, t.param(value))
+	default:
+		untranslatable(e, "multi-valued fields cannot be compared with %s", operator)
+		return ""
+	}
+}
+
+// quantifier translates the quantifiers applied to arrays to subqueries on their elements
+func (t *sqlTranslator) quantifier(e *QuantExpr) string {
+	column, typ := t.column(e, e.Field)
+	if typ != TypeList {
+		// single-valued fields behave as lists of one value
+		if e.Quantifier == quantNone {
+			return fmt.Sprintf("NOT (%s)", t.translate(e.Expression))
+		}
+		return t.translate(e.Expression)
+	}
+
+	t.quantified = e.Field
+	defer func() { t.quantified = "" }()
+	condition := t.translate(e.Expression)
+	switch e.Quantifier {
+	case quantAny:
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(%s) AS elem WHERE %s)", column, condition)
+	case quantAll:
+		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM unnest(%s) AS elem WHERE (%s) IS NOT TRUE)", column, condition)
+	default:
+		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM unnest(%s) AS elem WHERE %s)", column, condition)
+	}
+}
+
+// fieldComparison translates the comparisons between two fields, e.g. exited<$created
+func (t *sqlTranslator) fieldComparison(e *ValueExpr) string {
+	left, isField := e.Left.(*FieldOperand)
+	right, isRef := e.Right.(*RefOperand)
+	if !isField || !isRef {
+		untranslatable(e, "functions and arithmetic are not supported")
+	}
+	leftColumn, leftType := t.column(e, left.Field)
+	rightColumn, rightType := t.column(e, right.Field)
+	operator := e.Operator
+	switch {
+	case operator == "~" || operator == "!~":
+		untranslatable(e, "fields cannot be compared with %s", operator)
+	case leftType == TypeList || rightType == TypeList:
+		untranslatable(e, "multi-valued fields cannot be compared to other fields")
+	case leftType == TypeDuration:
+		// the older a time, the greater its age
+		if swapped, found := orderedOperators[operator]; found {
+			operator = swapped
+		}
+	}
+	return fmt.Sprintf("%s %s %s", leftColumn, sqlOperators[operator], rightColumn)
+}
+
+// escapeLike escapes the LIKE wildcards of a value
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
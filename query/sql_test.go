@@ -0,0 +1,96 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	translateFields = map[string][]Operator{
+		"running": {IS},
+		"name":    {EQ, LIKE},
+		"tag":     {EQ, LIKE},
+		"exit":    {EQ, GT},
+		"created": {EQ, GT},
+		"exited":  {EQ, GT},
+		"size":    {EQ, GT},
+		"label.*": {IS, EQ, LIKE},
+	}
+	translateTypes = map[string]Type{
+		"running": TypeBool,
+		"name":    TypeString,
+		"tag":     TypeList,
+		"exit":    TypeInt,
+		"created": TypeDuration,
+		"exited":  TypeDuration,
+		"size":    TypeSize,
+		"label.*": TypeString,
+	}
+)
+
+func TestToSQL(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return base }
+	defer func() { now = time.Now }()
+
+	columns := map[string]string{
+		"running": "running",
+		"name":    "name",
+		"tag":     "tags",
+		"exit":    "exit_code",
+		"created": "created_at",
+		"exited":  "finished_at",
+		"size":    "size",
+		"label.*": "labels->>%s",
+	}
+
+	cases := []struct {
+		query string
+		where string
+		args  []interface{}
+	}{
+		{"running", "running IS TRUE", nil},
+		{"!running & exit!=0", "(NOT (running IS TRUE) AND exit_code <> $1)", []interface{}{int64(0)}},
+		{"name~50%_off | size>=1KB", "(name ILIKE $1 OR size >= $2)", []interface{}{`%50\%\_off%`, int64(1024)}},
+		{"created>2w", "created_at < $1", []interface{}{base.Add(-14 * 24 * time.Hour)}},
+		{"label.env=prod & label.team", "(labels->>$1 = $2 AND labels->>$3 IS NOT NULL)", []interface{}{"env", "prod", "team"}},
+		{"exit is null", "exit_code IS NULL", nil},
+		{"tag=latest", "$1 = ANY(tags)", []interface{}{"latest"}},
+		{"tag!~dev", "NOT EXISTS (SELECT 1 FROM unnest(tags) AS elem WHERE elem ILIKE $1)", []interface{}{"%dev%"}},
+		{"all(tag~registry | tag=latest)", "NOT EXISTS (SELECT 1 FROM unnest(tags) AS elem WHERE ((elem ILIKE $1 OR elem = $2)) IS NOT TRUE)", []interface{}{"%registry%", "latest"}},
+		{"none(tag=x) & any(name=web)", "(NOT EXISTS (SELECT 1 FROM unnest(tags) AS elem WHERE elem = $1) AND name = $2)", []interface{}{"x", "web"}},
+		{"exited<$created", "finished_at > created_at", nil},
+	}
+
+	for _, cas := range cases {
+		ast, err := Parse(cas.query, translateFields, Types(translateTypes))
+		require.NoError(t, err, cas.query)
+
+		where, args, err := ToSQL(ast, columns, translateTypes)
+		require.NoError(t, err, cas.query)
+		require.Equal(t, cas.where, where, cas.query)
+		require.Equal(t, cas.args, args, cas.query)
+	}
+
+	for _, query := range []string{
+		"len(tag)>2",
+		"exited < $created - 1h",
+		"name~$label.env",
+		"tag=$name",
+	} {
+		ast, err := Parse(query, translateFields, Types(translateTypes))
+		require.NoError(t, err, query)
+
+		_, _, err = ToSQL(ast, columns, translateTypes)
+		require.Error(t, err, query)
+		require.IsType(t, TranslateError{}, err)
+		t.Log(err)
+	}
+
+	ast, err := Parse("running", translateFields)
+	require.NoError(t, err)
+	_, _, err = ToSQL(ast, map[string]string{"name": "name"}, nil)
+	require.EqualError(t, err, "Cannot translate running: no mapping for field running")
+}
@@ -0,0 +1,354 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+/*
+FromStruct returns a queryable over a struct, or a pointer to a struct, whose fields are described by StructSchema.
+Nil pointers and zero times are missing values.
+*/
+func FromStruct(v interface{}) ValueQueryable {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("%T is not a struct", v))
+	}
+	return &structQueryable{value: value, fields: structFieldsOf(value.Type())}
+}
+
+/*
+StructSchema returns the fields map to pass to Parse and the field types to pass to the Types option
+for the queryables returned by FromStruct for values of the same type as v.
+
+The field names are set with the query struct tag, e.g. `query:"name"`, and default to the snake case Go field name,
+e.g. desired_state for DesiredState. Fields tagged with `query:"-"`, unexported fields, including unexported embedded structs, and fields of unsupported kinds are skipped.
+The fields of nested structs are named after their parent field, e.g. state.exit_code, except for the embedded structs
+whose fields are promoted, and maps with string keys are wildcard fields, e.g. label.* for a Label map.
+The recursive fields, e.g. a Parent *Node field of a Node struct, are skipped.
+
+Strings are compared with =, != and ~, integers, time.Duration and the age of time.Time values with every operator,
+and booleans are used without operator. Slices are multi-valued fields.
+*/
+func StructSchema(v interface{}) (map[string][]Operator, map[string]Type) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("%T is not a struct", v))
+	}
+
+	fields := map[string][]Operator{}
+	types := map[string]Type{}
+	for _, f := range structFieldsOf(t) {
+		fields[f.key()] = kindOperators[f.typ]
+		types[f.key()] = f.typ
+	}
+	return fields, types
+}
+
+// kindOperators are the operators supported by the struct fields depending on their type
+var kindOperators = map[Type][]Operator{
+	TypeBool:     {IS},
+	TypeString:   {EQ, LIKE},
+	TypeList:     {EQ, LIKE},
+	TypeInt:      {EQ, NE, GT, GE, LT, LE},
+	TypeDuration: {EQ, NE, GT, GE, LT, LE},
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// structField is a queryable field of a struct, possibly nested in other structs
+type structField struct {
+	name  string
+	index []int
+	typ   Type
+	// wildcard is true for maps, whose keys are appended to the name, e.g. label.env
+	wildcard bool
+}
+
+func (f structField) key() string {
+	if f.wildcard {
+		return f.name + ".*"
+	}
+	return f.name
+}
+
+// structFields caches the fields of the struct types, as computing them for every value would be wasteful
+var structFields sync.Map
+
+func structFieldsOf(t reflect.Type) []structField {
+	if fields, found := structFields.Load(t); found {
+		return fields.([]structField)
+	}
+	fields := collectFields(t, "", nil, map[reflect.Type]bool{})
+	structFields.Store(t, fields)
+	return fields
+}
+
+/*
+collectFields returns the fields of a struct type and of its nested structs.
+parents are the struct types being collected, whose fields are skipped when nested again, e.g. the parent of a tree node,
+as they would be nested endlessly.
+*/
+func collectFields(t reflect.Type, prefix string, index []int, parents map[reflect.Type]bool) []structField {
+	parents[t] = true
+	defer delete(parents, t)
+
+	var res []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Tag.Get("query")
+		if name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			if parents[ft] {
+				continue
+			}
+			childPrefix := prefix
+			if !f.Anonymous || name != "" {
+				childPrefix = prefix + fieldName(f, name) + "."
+			}
+			res = append(res, collectFields(ft, childPrefix, fieldIndex, parents)...)
+			continue
+		}
+		field := structField{name: prefix + fieldName(f, name), index: fieldIndex}
+		if ft.Kind() == reflect.Map {
+			if ft.Key().Kind() != reflect.String {
+				continue
+			}
+			field.wildcard = true
+			ft = ft.Elem()
+		}
+		typ, ok := kindType(ft)
+		if !ok || (field.wildcard && typ == TypeList) {
+			continue
+		}
+		field.typ = typ
+		res = append(res, field)
+	}
+	return res
+}
+
+func fieldName(f reflect.StructField, tag string) string {
+	if tag != "" {
+		return tag
+	}
+	return snakeCase(f.Name)
+}
+
+// snakeCase converts a Go identifier to snake case, keeping acronyms together, e.g. ImageID to image_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var res []rune
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				res = append(res, '_')
+			}
+		}
+		res = append(res, unicode.ToLower(r))
+	}
+	return string(res)
+}
+
+// kindType returns the query type of a Go type, or false if it is not supported
+func kindType(t reflect.Type) (Type, bool) {
+	switch {
+	case t == timeType, t == durationType:
+		return TypeDuration, true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return TypeString, true
+	case reflect.Bool:
+		return TypeBool, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt, true
+	case reflect.Slice, reflect.Array:
+		if elem, ok := kindType(t.Elem()); ok && elem != TypeList && elem != TypeBool {
+			return TypeList, true
+		}
+	}
+	return TypeAny, false
+}
+
+// structQueryable is the queryable returned by FromStruct
+type structQueryable struct {
+	value  reflect.Value
+	fields []structField
+}
+
+var _ SliceQueryable = &structQueryable{}
+
+func (s *structQueryable) Is(field string, operator Operator, value string) bool {
+	v, found := s.Value(field)
+	return isValue(v, found, operator, value)
+}
+
+/*
+Value returns the value of a field, converting integers to int, slices to []string and pointers to the value they point to.
+It panics if the field is unknown.
+*/
+func (s *structQueryable) Value(field string) (interface{}, bool) {
+	for _, f := range s.fields {
+		switch {
+		case f.name == field:
+			v, found := fieldByIndex(s.value, f.index)
+			if !found {
+				return nil, false
+			}
+			return convertValue(v)
+		case f.wildcard && strings.HasPrefix(field, f.name+"."):
+			m, found := fieldByIndex(s.value, f.index)
+			if !found || m.IsNil() {
+				return nil, false
+			}
+			v := m.MapIndex(reflect.ValueOf(strings.TrimPrefix(field, f.name+".")).Convert(m.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+			return convertValue(v)
+		}
+	}
+	panic(fmt.Sprintf("Invalid field %s", field))
+}
+
+// Elements returns a queryable per value of a slice field, a single one for the other fields, and none for missing values
+func (s *structQueryable) Elements(field string) []Queryable {
+	value, found := s.Value(field)
+	if !found {
+		return nil
+	}
+	values, ok := value.([]string)
+	if !ok {
+		return []Queryable{valueElement{field: field, value: value}}
+	}
+	res := make([]Queryable, len(values))
+	for i, value := range values {
+		res[i] = valueElement{field: field, value: value}
+	}
+	return res
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, except that it returns false on nil pointers instead of panicking
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// convertValue converts a struct field to the value types used by the queryables
+func convertValue(v reflect.Value) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		return t, !t.IsZero()
+	case v.Type() == durationType:
+		return time.Duration(v.Int()), true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	case reflect.Slice, reflect.Array:
+		res := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if element, found := convertValue(v.Index(i)); found {
+				res = append(res, fmt.Sprint(element))
+			}
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+// valueElement is the queryable of a single value of a field, for the any, all and none quantifiers
+type valueElement struct {
+	field string
+	value interface{}
+}
+
+func (e valueElement) Is(field string, operator Operator, value string) bool {
+	if field != e.field {
+		panic(fmt.Sprintf("Invalid field %s, was expecting %s", field, e.field))
+	}
+	return isValue(e.value, true, operator, value)
+}
+
+func (e valueElement) Value(field string) (interface{}, bool) {
+	if field != e.field {
+		panic(fmt.Sprintf("Invalid field %s, was expecting %s", field, e.field))
+	}
+	return e.value, true
+}
+
+/*
+isValue implements Queryable.Is for a field value: without operator, it is true if the field is set, or for booleans, true,
+and times are compared as durations since then, like in created>2w
+*/
+func isValue(value interface{}, found bool, operator Operator, pattern string) bool {
+	if operator == IS {
+		if b, ok := value.(bool); ok {
+			return found && b
+		}
+		return found
+	}
+	if !found {
+		return false
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return isValue(now().Sub(v), true, operator, pattern)
+	case time.Duration:
+		d, err := ParseDuration(pattern)
+		if err != nil {
+			return false
+		}
+		return order(int64(v), int64(d), operator)
+	}
+	return valueIs(value, operator, pattern)
+}
@@ -0,0 +1,164 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Meta struct {
+	Labels map[string]string `query:"label"`
+}
+
+type testState struct {
+	Running  bool
+	ExitCode *int
+	Finished time.Time
+}
+
+type testContainer struct {
+	Meta
+	ImageID  string
+	Name     string `query:"name"`
+	Ports    []uint16
+	Cmd      []string
+	State    *testState
+	Timeout  time.Duration
+	Ignored  string `query:"-"`
+	internal string
+	Handler  func()
+}
+
+func TestStructSchema(t *testing.T) {
+	fields, types := StructSchema(&testContainer{})
+
+	ordered := []Operator{EQ, NE, GT, GE, LT, LE}
+	require.Equal(t, map[string][]Operator{
+		"label.*":         {EQ, LIKE},
+		"image_id":        {EQ, LIKE},
+		"name":            {EQ, LIKE},
+		"ports":           {EQ, LIKE},
+		"cmd":             {EQ, LIKE},
+		"state.running":   {IS},
+		"state.exit_code": ordered,
+		"state.finished":  ordered,
+		"timeout":         ordered,
+	}, fields)
+	require.Equal(t, map[string]Type{
+		"label.*":         TypeString,
+		"image_id":        TypeString,
+		"name":            TypeString,
+		"ports":           TypeList,
+		"cmd":             TypeList,
+		"state.running":   TypeBool,
+		"state.exit_code": TypeInt,
+		"state.finished":  TypeDuration,
+		"timeout":         TypeDuration,
+	}, types)
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Name":         "name",
+		"DesiredState": "desired_state",
+		"ImageID":      "image_id",
+		"HTTPPort":     "http_port",
+		"IPv6":         "i_pv6",
+		"Size2GB":      "size2_gb",
+	} {
+		require.Equal(t, expected, snakeCase(name), name)
+	}
+}
+
+func TestFromStruct(t *testing.T) {
+	exit := 1
+	containers := map[string]*testContainer{
+		"web": {
+			Meta:    Meta{Labels: map[string]string{"env": "prod"}},
+			Name:    "web",
+			Ports:   []uint16{80, 443},
+			Cmd:     []string{"nginx", "-g"},
+			State:   &testState{ExitCode: &exit, Finished: time.Now().Add(-2 * time.Hour)},
+			Timeout: 30 * time.Second,
+		},
+		"db": {
+			Name:  "db",
+			State: &testState{Running: true},
+		},
+		"new": {
+			Name: "new",
+		},
+	}
+	fields, types := StructSchema(testContainer{})
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"name=web", []string{"web"}},
+		{"label.env=prod", []string{"web"}},
+		{"label.env is null", []string{"db", "new"}},
+		{"state.running", []string{"db"}},
+		{"!state.running", []string{"new", "web"}},
+		{"state.exit_code<2", []string{"web"}},
+		{"state.exit_code!=1", nil},
+		{"state.finished>1h", []string{"web"}},
+		{"state.finished is null", []string{"db", "new"}},
+		{"ports=443", []string{"web"}},
+		{"all(cmd~n)", []string{"db", "new"}},
+		{"any(cmd=-g)", []string{"web"}},
+		{"count(ports)=2", []string{"web"}},
+		{"timeout>=30s & timeout<1m", []string{"web"}},
+	}
+
+	for _, cas := range cases {
+		ast, err := Parse(cas.query, fields, Types(types))
+		require.NoError(t, err, cas.query)
+
+		var actual []string
+		for _, name := range []string{"db", "new", "web"} {
+			if ast.Match(FromStruct(containers[name])) {
+				actual = append(actual, name)
+			}
+		}
+		require.Equal(t, cas.expected, actual, cas.query)
+	}
+
+	_, err := Parse("internal=x", fields)
+	require.Error(t, err)
+	require.Panics(t, func() { FromStruct("web") })
+}
+
+type testNode struct {
+	Name     string
+	Parent   *testNode
+	Children []testNode
+	Owner    *testOwner
+	Previous *testState
+	Current  *testState
+}
+
+type testOwner struct {
+	Name  string
+	Nodes []*testNode
+	Home  *testNode
+}
+
+func TestStructSchemaRecursive(t *testing.T) {
+	fields, types := StructSchema(testNode{})
+	require.Equal(t, map[string]Type{
+		"name":               TypeString,
+		"owner.name":         TypeString,
+		"previous.running":   TypeBool,
+		"previous.exit_code": TypeInt,
+		"previous.finished":  TypeDuration,
+		"current.running":    TypeBool,
+		"current.exit_code":  TypeInt,
+		"current.finished":   TypeDuration,
+	}, types)
+
+	ast, err := Parse("name=leaf & owner.name=ops", fields, Types(types))
+	require.NoError(t, err)
+	require.True(t, ast.Match(FromStruct(&testNode{Name: "leaf", Parent: &testNode{Name: "root"}, Owner: &testOwner{Name: "ops"}})))
+}
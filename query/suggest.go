@@ -0,0 +1,152 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// comparisonOperators are the comparison operators, in the order they are suggested
+var comparisonOperators = []string{"=", "!=", "~", "!~", ">", ">=", "<", "<="}
+
+// operatorList returns the comparison operators a field supports, either natively or derived from EQ, LIKE and GT
+func operatorList(operators []Operator) []string {
+	var supported []string
+	for _, op := range comparisonOperators {
+		if hasOperator(operators, Operator(op)) || hasOperator(operators, operatorMapping[op]) {
+			supported = append(supported, op)
+		}
+	}
+	return supported
+}
+
+// supportedOperators lists the operators a field supports for the error messages, e.g. ", supported operators: =, !="
+func supportedOperators(operators []Operator) string {
+	supported := operatorList(operators)
+	switch {
+	case len(supported) != 0:
+		return ", supported operators: " + strings.Join(supported, ", ")
+	case hasOperator(operators, IS):
+		return ", it can only be used without an operator"
+	default:
+		return ""
+	}
+}
+
+// didYouMean returns the suggestion part of an error message, if there is a suggestion
+func didYouMean(prefix, suggestion string) string {
+	if len(suggestion) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s%s?", prefix, suggestion)
+}
+
+// enumerate joins the items with commas, and the conjunction before the last one, e.g. "a, b or c"
+func enumerate(items []string, conjunction string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conjunction + " " + items[len(items)-1]
+}
+
+// suggestion is a name suggested instead of an unknown one, and its edit distance to it
+type suggestion struct {
+	name     string
+	distance int
+}
+
+/*
+suggestField returns the known field closest to an unknown one, or an empty string if none is close enough.
+The wildcard fields are compared on their prefix only, e.g. lable.arch is close to label.*, and suggested as label.arch.
+*/
+func suggestField(fields map[string][]Operator, field string) string {
+	var suggestions []suggestion
+	for k := range fields {
+		if !strings.HasSuffix(k, "*") {
+			suggestions = append(suggestions, suggestion{name: k, distance: distance(field, k)})
+			continue
+		}
+		prefix := strings.TrimSuffix(k, "*")
+		segments := strings.Count(prefix, ".")
+		parts := strings.SplitAfterN(field, ".", segments+1)
+		if segments == 0 || len(parts) <= segments {
+			continue
+		}
+		suggestions = append(suggestions, suggestion{
+			name:     prefix + parts[segments],
+			distance: distance(strings.Join(parts[:segments], ""), prefix),
+		})
+	}
+	return closest(field, suggestions)
+}
+
+// suggestFunction returns the function or quantifier closest to an unknown one, or an empty string if none is close enough
+func suggestFunction(name string) string {
+	var suggestions []suggestion
+	for function := range functions {
+		suggestions = append(suggestions, suggestion{name: function, distance: distance(name, function)})
+	}
+	for quantifier := range quantifiers {
+		suggestions = append(suggestions, suggestion{name: quantifier, distance: distance(name, quantifier)})
+	}
+	return closest(name, suggestions)
+}
+
+// closest returns the closest suggestion, the first in alphabetical order for equal distances, if it is close enough
+func closest(name string, suggestions []suggestion) string {
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+	// one typo for short names, two for the others
+	maxDistance := 2
+	if len(name) <= 4 {
+		maxDistance = 1
+	}
+	if len(suggestions) == 0 || suggestions[0].distance > maxDistance || suggestions[0].name == name {
+		return ""
+	}
+	return suggestions[0].name
+}
+
+/*
+distance returns the edit distance between two strings: the number of rune insertions, deletions, substitutions
+and transpositions of adjacent runes needed to turn one into the other, e.g. 1 between nmae and name
+*/
+func distance(a, b string) int {
+	s, t := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	// d[i][j] is the distance between the first i runes of s and the first j runes of t
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = smallest(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = smallest(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func smallest(values ...int) int {
+	res := values[0]
+	for _, v := range values[1:] {
+		if v < res {
+			res = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TranslateError is returned when a query cannot be translated to another backend, e.g. a function call
+type TranslateError struct {
+	// Expression is the expression which cannot be translated
+	Expression Expression
+	Message    string
+}
+
+func (e TranslateError) Error() string {
+	return fmt.Sprintf("Cannot translate %v: %s", e.Expression, e.Message)
+}
+
+// untranslatable aborts a translation, the error being returned by the translation entry point
+func untranslatable(expr Expression, msg string, args ...interface{}) {
+	panic(TranslateError{Expression: expr, Message: fmt.Sprintf(msg, args...)})
+}
+
+// recoverTranslation converts the TranslateError panics to an error
+func recoverTranslation(err *error) {
+	if r := recover(); r != nil {
+		translateErr, ok := r.(TranslateError)
+		if !ok {
+			panic(r)
+		}
+		*err = translateErr
+	}
+}
+
+/*
+translateField returns the name a field is mapped to, using the same keys as the fields map, e.g. "label.*".
+The mappings of the wildcard fields contain a %s, replaced by key(suffix), where suffix is the part of the field
+matched by the wildcard, e.g. env for label.env.
+*/
+func translateField(mapping map[string]string, expr Expression, field string, key func(string) string) string {
+	if name, found := mapping[field]; found {
+		return name
+	}
+	for k, name := range mapping {
+		if matchesWildcard(k, field) {
+			if !strings.Contains(name, "%s") {
+				untranslatable(expr, "the mapping of %s should contain %%s", k)
+			}
+			return fmt.Sprintf(name, key(strings.TrimPrefix(field, strings.TrimSuffix(k, "*"))))
+		}
+	}
+	untranslatable(expr, "no mapping for field %s", field)
+	return ""
+}
+
+// orderedOperators are the comparison operators, and their equivalent when the operands are swapped
+var orderedOperators = map[string]string{
+	">":  "<",
+	">=": "<=",
+	"<":  ">",
+	"<=": ">=",
+}
+
+// negatedOperators are the comparison operators, and the operator matching when they do not, for the values which are set
+var negatedOperators = map[string]string{
+	"=":  "!=",
+	"!=": "=",
+	"~":  "!~",
+	"!~": "~",
+	">":  "<=",
+	"<=": ">",
+	">=": "<",
+	"<":  ">=",
+}
+
+/*
+translateValue converts the value of a comparison to the type of the field, and returns it along with the operator to use.
+Integers and sizes are converted to int64, and durations to the time they are the age of, e.g. created>2w is translated
+to created<(now - 2w), which is why the operator is swapped.
+The values of fields with no declared type are only converted for the ordering operators, except for integers.
+*/
+func translateValue(t Type, operator, value string) (interface{}, string) {
+	_, ordered := orderedOperators[operator]
+	if t == TypeString || t == TypeList || operator == "~" || operator == "!~" {
+		return value, operator
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i, operator
+	}
+	if t == TypeDuration || (t == TypeAny && ordered) {
+		if d, err := ParseDuration(value); err == nil {
+			if swapped, found := orderedOperators[operator]; found {
+				operator = swapped
+			}
+			return now().Add(-d), operator
+		}
+	}
+	if t == TypeSize || (t == TypeAny && ordered) {
+		if s, err := ParseSize(value); err == nil {
+			return s, operator
+		}
+	}
+	return value, operator
+}
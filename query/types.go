@@ -0,0 +1,51 @@
+package query
+
+import "strings"
+
+// Type is the type of the values of a field
+type Type string
+
+const (
+	// TypeAny is the type of the fields with no declared type, whose values are only checked by the queryable
+	TypeAny Type = ""
+	// TypeBool is the type of the boolean fields, used without an operator
+	TypeBool Type = "bool"
+	// TypeString is the type of the string fields
+	TypeString Type = "string"
+	// TypeList is the type of the multi-valued string fields
+	TypeList Type = "list"
+	// TypeInt is the type of the integer fields
+	TypeInt Type = "int"
+	// TypeSize is the type of the size fields, compared against sizes like 200MB
+	TypeSize Type = "size"
+	// TypeDuration is the type of the duration fields, compared against durations like 2w
+	TypeDuration Type = "duration"
+)
+
+/*
+Types declares the types of the fields, with the same keys as the fields map, e.g. "label.*".
+Knowing the field types allows the parser to reject invalid function calls, e.g. count on a string field.
+*/
+func Types(types map[string]Type) Option {
+	return func(p *parser) {
+		p.types = types
+	}
+}
+
+// lookupType returns the type of a field, taking wildcard fields like "label.*" into account
+func lookupType(types map[string]Type, field string) Type {
+	if t, found := types[field]; found {
+		return t
+	}
+	for k, t := range types {
+		if matchesWildcard(k, field) {
+			return t
+		}
+	}
+	return TypeAny
+}
+
+// matchesWildcard returns true if the key of a fields map is a wildcard, e.g. "label.*", or "*" for any field, matching the field
+func matchesWildcard(key, field string) bool {
+	return key == "*" || strings.HasSuffix(key, ".*") && strings.HasPrefix(field, strings.TrimSuffix(key, ".*"))
+}
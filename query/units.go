@@ -0,0 +1,42 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+formatUnits renders value using the two most significant of the provided units, which must be sorted
+from the largest to the smallest. The remainder below the second unit is dropped.
+*/
+func formatUnits(value int64, units []string, multipliers map[string]int64) string {
+	var parts []string
+	for _, u := range units {
+		if mul := multipliers[u]; value >= mul {
+			parts = append(parts, fmt.Sprintf("%d%s", value/mul, u))
+			value %= mul
+		}
+		if len(parts) == 2 || (len(parts) > 0 && value == 0) {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// unitParser holds the state shared by the duration and size parsers
+type unitParser struct {
+	input string
+	pos   int
+}
+
+func (p *unitParser) eatWs() {
+	for ; p.pos < len(p.input); p.pos++ {
+		if p.input[p.pos] != ' ' {
+			return
+		}
+	}
+}
+
+func (p *unitParser) eof() bool {
+	return p.pos >= len(p.input)
+}
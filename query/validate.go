@@ -0,0 +1,213 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+validate checks an expression which was not parsed from a query, e.g. decoded from JSON, against the fields
+like Parse checks the parsed queries, and returns the expression Parse returns for the same query, or a ParseError without input.
+The expression is checked node by node instead of being formatted and parsed back, so that field names or operators
+containing query syntax, e.g. a "running #" field, are rejected instead of changing the query.
+*/
+func validate(expr Expression, fields map[string][]Operator, options ...Option) (res Expression, err error) {
+	p := &parser{
+		lexer:  newLexer(""),
+		fields: fields,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case string, posError, syntaxError:
+				res, err = nil, p.parseError(r)
+			default:
+				// e.g. a runtime error, which is not a validation failure
+				panic(r)
+			}
+		}
+	}()
+	return p.validate(expr), nil
+}
+
+func (p *parser) validate(expr Expression) Expression {
+	switch e := expr.(type) {
+	case *OrExpr:
+		return &OrExpr{Left: p.validate(e.Left), Right: p.validate(e.Right)}
+	case *AndExpr:
+		return &AndExpr{Left: p.validate(e.Left), Right: p.validate(e.Right)}
+	case *NotExpr:
+		return &NotExpr{Expression: p.validate(e.Expression)}
+	case *CompExpr:
+		var right Operand
+		if len(e.Operator) != 0 {
+			right = &LiteralOperand{Text: e.Value}
+		}
+		return p.validateComparison(&FieldOperand{Field: e.Field}, e.Operator, right)
+	case *ValueExpr:
+		right := e.Right
+		if right == nil && len(e.Operator) != 0 {
+			right = &LiteralOperand{Text: e.Value}
+		}
+		return p.validateComparison(e.Left, e.Operator, right)
+	case *NullExpr:
+		p.checkField(e.Field)
+		return &NullExpr{Field: e.Field, Negated: e.Negated}
+	case *QuantExpr:
+		quantifier := strings.ToLower(e.Quantifier)
+		if !quantifiers[quantifier] {
+			panic(fmt.Sprintf("Unknown quantifier %q", e.Quantifier))
+		}
+		return p.quantified(quantifier, p.validate(e.Expression))
+	case nil:
+		panic("missing expression")
+	default:
+		panic(fmt.Sprintf("unsupported expression %T", expr))
+	}
+}
+
+/*
+validateComparison checks a comparison, or the test of a value without operator if right is nil,
+and returns a CompExpr for the comparisons of a field to a literal, like Parse does
+*/
+func (p *parser) validateComparison(left Operand, operator string, right Operand) Expression {
+	left, t := p.validateOperand(left)
+	field, isField := left.(*FieldOperand)
+	operators, subject := typeOperators[t], left.String()
+	if isField {
+		operators, _ = p.fieldOperators(field.Field)
+		subject = "field " + field.Field
+	}
+
+	if len(operator) == 0 {
+		if !hasOperator(operators, IS) {
+			panic(fmt.Sprintf("%s cannot be used without an operator%s", subject, supportedOperators(operators)))
+		}
+		if isField {
+			return &CompExpr{Field: field.Field}
+		}
+		return &ValueExpr{Left: left}
+	}
+
+	if _, known := operatorMapping[operator]; !known {
+		panic(fmt.Sprintf("Unknown operator %q", operator))
+	}
+	native := isField && nativeOperators[operator] && hasOperator(operators, Operator(operator))
+	if !native && !hasOperator(operators, operatorMapping[operator]) {
+		panic(fmt.Sprintf("%s does not support operator %s%s", subject, operator, supportedOperators(operators)))
+	}
+
+	right, rightType := p.validateRight(right)
+	switch r := right.(type) {
+	case *LiteralOperand:
+		checkValue(left, t, r.Text)
+		if isField {
+			return &CompExpr{Field: field.Field, Operator: operator, Value: r.Text, Derived: !native && nativeOperators[operator]}
+		}
+		return &ValueExpr{Left: left, Operator: operator, Value: r.Text}
+	case *ArithOperand:
+		if operatorMapping[operator] == LIKE {
+			panic(fmt.Sprintf("operator %s cannot be used with an arithmetic expression", operator))
+		}
+	}
+	if !compatible(t, rightType) {
+		panic(fmt.Sprintf("%v (%s) cannot be compared to %v (%s)", left, t, right, rightType))
+	}
+	return &ValueExpr{Left: left, Operator: operator, Right: right}
+}
+
+// validateOperand checks the left side of a comparison: a field, a function call or an index, and returns its type
+func (p *parser) validateOperand(op Operand) (Operand, Type) {
+	switch o := op.(type) {
+	case *FieldOperand:
+		p.checkField(o.Field)
+		return &FieldOperand{Field: o.Field}, lookupType(p.types, o.Field)
+	case *CallOperand:
+		function, found := functions[o.Function]
+		if !found {
+			panic(fmt.Sprintf("Unknown function %s%s", o.Function, didYouMean("", suggestFunction(o.Function))))
+		}
+		switch o.Arg.(type) {
+		case *FieldOperand, *CallOperand, *IndexOperand:
+		default:
+			panic(fmt.Sprintf("was expecting a field name in %s(...)", o.Function))
+		}
+		arg, argType := p.validateOperand(o.Arg)
+		if !function.accepts(argType) {
+			panic(fmt.Sprintf("%s(...) cannot be applied to the %s value %v", o.Function, argType, arg))
+		}
+		if len(o.Params) != function.Params {
+			panic(fmt.Sprintf("%s(...) was expecting %d parameters after %v", o.Function, function.Params, arg))
+		}
+		return &CallOperand{Function: o.Function, Arg: arg, Params: append([]string(nil), o.Params...)}, function.Output
+	case *IndexOperand:
+		switch o.List.(type) {
+		case *CallOperand, *IndexOperand:
+		default:
+			panic(fmt.Sprintf("%v cannot be indexed", o.List))
+		}
+		list, t := p.validateOperand(o.List)
+		if t != TypeList && t != TypeAny {
+			panic(fmt.Sprintf("the %s value %v cannot be indexed", t, list))
+		}
+		return &IndexOperand{List: list, Index: o.Index}, TypeString
+	case nil:
+		panic("missing operand")
+	default:
+		panic(fmt.Sprintf("%v cannot be on the left side of a comparison", op))
+	}
+}
+
+/*
+validateRight checks the right side of a comparison: a literal, a $field reference or an arithmetic expression of them,
+the references to variables being replaced with their values like Parse does, and returns its type
+*/
+func (p *parser) validateRight(op Operand) (Operand, Type) {
+	switch o := op.(type) {
+	case *LiteralOperand:
+		return &LiteralOperand{Text: o.Text}, TypeAny
+	case *RefOperand:
+		if value, found := p.variables[o.Field]; found {
+			return &LiteralOperand{Text: value}, TypeAny
+		}
+		if _, found := p.fieldOperators(o.Field); found {
+			p.checkField(o.Field)
+			return &RefOperand{FieldOperand{Field: o.Field}}, lookupType(p.types, o.Field)
+		}
+		if p.environment != nil {
+			if value, found := p.environment(o.Field); found {
+				return &LiteralOperand{Text: value}, TypeAny
+			}
+		}
+		panic(fmt.Sprintf("Unknown field or variable $%s%s", o.Field, didYouMean("$", suggestField(p.fields, o.Field))))
+	case *ArithOperand:
+		if !arithOperators[o.Operator] {
+			panic(fmt.Sprintf("Unknown arithmetic operator %q", o.Operator))
+		}
+		left, leftType := p.arithOperand(p.validateRight(o.Left))
+		right, rightType := p.arithOperand(p.validateRight(o.Right))
+		t, ok := arithType(o.Operator, leftType, rightType)
+		if !ok {
+			panic(fmt.Sprintf("cannot compute %v %s %v: operator %s is not supported between %s and %s",
+				left, o.Operator, right, o.Operator, leftType, rightType))
+		}
+		return &ArithOperand{Operator: o.Operator, Left: left, Right: right}, t
+	case nil:
+		panic("missing operand")
+	default:
+		panic(fmt.Sprintf("%v cannot be on the right side of a comparison", op))
+	}
+}
+
+// checkField checks that a field exists, and that its name is written as in a query, e.g. without spaces or operators
+func (p *parser) checkField(field string) {
+	if needsQuotes(field) {
+		panic(fmt.Sprintf("Invalid field name %q", field))
+	}
+	if _, found := p.fieldOperators(field); !found {
+		panic(fmt.Sprintf("Unknown field %s%s", field, didYouMean("", suggestField(p.fields, field))))
+	}
+}
@@ -0,0 +1,105 @@
+package query
+
+import "sort"
+
+/*
+Visitor is called by Walk for every expression of a query.
+If Visit returns a non-nil visitor w, Walk visits the children of the expression with w.
+*/
+type Visitor interface {
+	Visit(expr Expression) (w Visitor)
+}
+
+/*
+Walk traverses a query in depth-first order, like go/ast.Walk: it calls v.Visit(expr), and then walks the children of expr
+with the visitor it returns, if not nil.
+The children are the sides of OrExpr and AndExpr nodes and the expressions of NotExpr and QuantExpr nodes.
+*/
+func Walk(v Visitor, expr Expression) {
+	if v = v.Visit(expr); v == nil {
+		return
+	}
+	for _, child := range children(expr) {
+		Walk(v, child)
+	}
+}
+
+// inspector is the Visitor of Inspect
+type inspector func(Expression) bool
+
+func (f inspector) Visit(expr Expression) Visitor {
+	if f(expr) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a query in depth-first order, calling f for every expression, and stops descending when f returns false
+func Inspect(expr Expression, f func(Expression) bool) {
+	Walk(inspector(f), expr)
+}
+
+func children(expr Expression) []Expression {
+	switch e := expr.(type) {
+	case *OrExpr:
+		return []Expression{e.Left, e.Right}
+	case *AndExpr:
+		return []Expression{e.Left, e.Right}
+	case *NotExpr:
+		return []Expression{e.Expression}
+	case *QuantExpr:
+		return []Expression{e.Expression}
+	default:
+		return nil
+	}
+}
+
+/*
+Fields returns the sorted names of the fields a query uses, including the fields referenced with $field
+and the fields passed to functions, e.g. to check that a user is allowed to query them
+*/
+func Fields(expr Expression) []string {
+	fields := map[string]bool{}
+	Inspect(expr, func(e Expression) bool {
+		switch e := e.(type) {
+		case *CompExpr:
+			fields[e.Field] = true
+		case *NullExpr:
+			fields[e.Field] = true
+		case *QuantExpr:
+			fields[e.Field] = true
+		case *ValueExpr:
+			operandFields(e.Left, fields)
+			if e.Right != nil {
+				operandFields(e.Right, fields)
+			}
+		}
+		return true
+	})
+
+	res := make([]string, 0, len(fields))
+	for field := range fields {
+		res = append(res, field)
+	}
+	sort.Strings(res)
+	return res
+}
+
+/*
+Rewrite returns a copy of a query where every expression is replaced by the result of fn, bottom-up:
+fn is called on an expression after its children have been rewritten, and returns either the expression or its replacement.
+The original query is left untouched.
+*/
+func Rewrite(expr Expression, fn func(Expression) Expression) Expression {
+	switch e := expr.(type) {
+	case *OrExpr:
+		expr = &OrExpr{Left: Rewrite(e.Left, fn), Right: Rewrite(e.Right, fn)}
+	case *AndExpr:
+		expr = &AndExpr{Left: Rewrite(e.Left, fn), Right: Rewrite(e.Right, fn)}
+	case *NotExpr:
+		expr = &NotExpr{Expression: Rewrite(e.Expression, fn)}
+	case *QuantExpr:
+		expr = &QuantExpr{Quantifier: e.Quantifier, Field: e.Field, Expression: Rewrite(e.Expression, fn)}
+	}
+	return fn(expr)
+}
@@ -0,0 +1,90 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a Visitor recording the visited expressions, and not descending into quantifiers
+type recorder struct {
+	visited []string
+}
+
+func (r *recorder) Visit(expr Expression) Visitor {
+	r.visited = append(r.visited, fmt.Sprintf("%T", expr))
+	if _, ok := expr.(*QuantExpr); ok {
+		return nil
+	}
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	ast, err := Parse("running & !(name~web | any(tag=x)) | exit is null", MapFields)
+	require.NoError(t, err)
+
+	r := &recorder{}
+	Walk(r, ast)
+	require.Equal(t, []string{
+		"*query.OrExpr",
+		"*query.AndExpr",
+		"*query.CompExpr",
+		"*query.NotExpr",
+		"*query.OrExpr",
+		"*query.CompExpr",
+		"*query.QuantExpr",
+		"*query.NullExpr",
+	}, r.visited)
+
+	var comps []string
+	Inspect(ast, func(e Expression) bool {
+		if c, ok := e.(*CompExpr); ok {
+			comps = append(comps, c.Field)
+		}
+		_, isNot := e.(*NotExpr)
+		return !isNot
+	})
+	require.Equal(t, []string{"running"}, comps)
+}
+
+func TestFields(t *testing.T) {
+	ast, err := Parse(`running & len(split(image, ":")[0])>3 & label.version!=$image.label.version & all(tag~x) & exit is null`, MapFields)
+	require.NoError(t, err)
+	require.Equal(t, []string{"exit", "image", "image.label.version", "label.version", "running", "tag"}, Fields(ast))
+}
+
+func TestRewrite(t *testing.T) {
+	ast, err := Parse("running & !(name=web | any(tag=x))", MapFields)
+	require.NoError(t, err)
+	original := ast.(*AndExpr).String()
+
+	rewritten := Rewrite(ast, func(e Expression) Expression {
+		switch e := e.(type) {
+		case *CompExpr:
+			if e.Field == "running" {
+				return &CompExpr{Field: "state", Operator: "=", Value: "running"}
+			}
+			return &CompExpr{Field: "meta." + e.Field, Operator: e.Operator, Value: e.Value}
+		case *NotExpr:
+			// !(a | b) is !a & !b
+			if or, ok := e.Expression.(*OrExpr); ok {
+				return &AndExpr{Left: &NotExpr{Expression: or.Left}, Right: &NotExpr{Expression: or.Right}}
+			}
+		}
+		return e
+	})
+
+	require.Equal(t, &AndExpr{
+		Left: &CompExpr{Field: "state", Operator: "=", Value: "running"},
+		Right: &AndExpr{
+			Left: &NotExpr{Expression: &CompExpr{Field: "meta.name", Operator: "=", Value: "web"}},
+			Right: &NotExpr{Expression: &QuantExpr{
+				Quantifier: quantAny,
+				Field:      "tag",
+				Expression: &CompExpr{Field: "meta.tag", Operator: "=", Value: "x"},
+			}},
+		},
+	}, rewritten)
+	require.Equal(t, original, ast.(*AndExpr).String())
+}
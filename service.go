@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/jawher/bateau/query"
+)
+
+var (
+	svcFields = map[string][]query.Operator{
+		"label.*": {query.IS, query.EQ, query.LIKE},
+
+		"id":    {query.EQ, query.LIKE},
+		"name":  {query.EQ, query.LIKE},
+		"mode":  {query.EQ, query.LIKE},
+		"image": {query.EQ, query.LIKE},
+
+		"replicas": orderOperators,
+		"created":  orderOperators,
+		"updated":  orderOperators,
+	}
+
+	svcTypes = map[string]query.Type{
+		"label.*": query.TypeString,
+
+		"id":    query.TypeString,
+		"name":  query.TypeString,
+		"mode":  query.TypeString,
+		"image": query.TypeString,
+
+		"replicas": query.TypeInt,
+		"created":  query.TypeDuration,
+		"updated":  query.TypeDuration,
+	}
+)
+
+type DockerService struct {
+	service swarm.Service
+}
+
+var _ query.SliceQueryable = &DockerService{}
+
+func listServices(client *docker.Client) ([]queryable, error) {
+	services, err := client.ListServices(docker.ListServicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]queryable, len(services))
+	for i, service := range services {
+		res[i] = &DockerService{service: service}
+	}
+	return res, nil
+}
+
+func (s *DockerService) labelKeys() []string {
+	return mapKeys(s.service.Spec.Labels)
+}
+
+func (s *DockerService) Is(field string, operator query.Operator, value string) bool {
+	v, found := s.Value(field)
+	return valueCompare(v, found, operator, value)
+}
+
+func (s *DockerService) Elements(field string) []query.Queryable {
+	return elements(s, field)
+}
+
+/*
+Value returns the value of the provided field, or false if the service has no value for it.
+*/
+func (s *DockerService) Value(field string) (interface{}, bool) {
+	spec := s.service.Spec
+	switch {
+	case strings.HasPrefix(field, "label."):
+		label := strings.TrimPrefix(field, "label.")
+		labelValue, found := spec.Labels[label]
+		return labelValue, found
+	case field == "id":
+		return s.service.ID, true
+	case field == "name":
+		return spec.Name, true
+	case field == "mode":
+		return serviceMode(spec.Mode), true
+	case field == "image":
+		if spec.TaskTemplate.ContainerSpec == nil {
+			return "", false
+		}
+		return spec.TaskTemplate.ContainerSpec.Image, true
+	case field == "replicas":
+		if spec.Mode.Replicated == nil || spec.Mode.Replicated.Replicas == nil {
+			return 0, false
+		}
+		return int(*spec.Mode.Replicated.Replicas), true
+	case field == "created":
+		return s.service.CreatedAt, true
+	case field == "updated":
+		return s.service.UpdatedAt, !s.service.UpdatedAt.IsZero()
+	default:
+		panic(fmt.Sprintf("Invalid field %s", field))
+	}
+}
+
+func serviceMode(mode swarm.ServiceMode) string {
+	switch {
+	case mode.Global != nil:
+		return "global"
+	case mode.ReplicatedJob != nil:
+		return "replicated-job"
+	case mode.GlobalJob != nil:
+		return "global-job"
+	default:
+		return "replicated"
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// sshCommand is the ssh client used to reach remote docker daemons
+var sshCommand = "ssh"
+
+// isSSH returns true for the ssh://[user@]host[:port][/socket] endpoints
+func isSSH(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "ssh://")
+}
+
+/*
+newSSHClient returns a docker client tunnelling the docker API over SSH.
+Requests are sent to a placeholder address as the actual connections are established by the sshDialer.
+*/
+func newSSHClient(endpoint string) (*docker.Client, error) {
+	dialer, err := newSSHDialer(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	client, err := docker.NewClient("http://docker.ssh")
+	if err != nil {
+		return nil, err
+	}
+	client.Dialer = dialer
+	client.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, network, address string) (net.Conn, error) {
+				return dialer.Dial(network, address)
+			},
+			IdleConnTimeout: 30 * time.Second,
+		},
+	}
+	return client, nil
+}
+
+/*
+sshDialer connects to a remote docker daemon the same way the docker CLI does:
+by running "docker system dial-stdio" on the remote host with the ssh command, and talking to the docker API
+over its standard input and output.
+Using the ssh command rather than an SSH library means that ~/.ssh/config and the ssh agent are honored.
+*/
+type sshDialer struct {
+	command string
+	args    []string
+}
+
+func newSSHDialer(endpoint string) (*sshDialer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ssh" || len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("Invalid ssh endpoint %s, should be ssh://[user@]host[:port][/socket]", endpoint)
+	}
+
+	var args []string
+	if u.User != nil {
+		args = append(args, "-l", u.User.Username())
+	}
+	if len(u.Port()) != 0 {
+		args = append(args, "-p", u.Port())
+	}
+	args = append(args, "--", u.Hostname(), "docker")
+	if len(u.Path) > 1 {
+		args = append(args, "--host", "unix://"+u.Path)
+	}
+	args = append(args, "system", "dial-stdio")
+
+	return &sshDialer{
+		command: sshCommand,
+		args:    args,
+	}, nil
+}
+
+func (d *sshDialer) Dial(network, address string) (net.Conn, error) {
+	cmd := exec.Command(d.command, d.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	conn := &commandConn{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	cmd.Stderr = &conn.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error while running %s: %v", d.command, err)
+	}
+	return conn, nil
+}
+
+/*
+commandConn is a net.Conn over the standard input and output of a command.
+Deadlines are not supported.
+*/
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr bytes.Buffer
+
+	waiting sync.Once
+	waitErr error
+}
+
+var _ net.Conn = &commandConn{}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	n, err := c.stdout.Read(b)
+	if err == io.EOF {
+		// the command exited: its error output, safe to read once it is waited for, tells why
+		if c.wait() != nil && c.stderr.Len() != 0 {
+			err = fmt.Errorf("%s: %s", c.cmd.Path, strings.TrimSpace(c.stderr.String()))
+		}
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.wait()
+	return nil
+}
+
+func (c *commandConn) wait() error {
+	c.waiting.Do(func() {
+		c.waitErr = c.cmd.Wait()
+	})
+	return c.waitErr
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string {
+	return "command"
+}
+
+func (commandAddr) String() string {
+	return "command"
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHDialerArgs(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		expected []string
+	}{
+		{"ssh://build-3", []string{"--", "build-3", "docker", "system", "dial-stdio"}},
+		{"ssh://deploy@build-3", []string{"-l", "deploy", "--", "build-3", "docker", "system", "dial-stdio"}},
+		{"ssh://deploy@build-3:2222", []string{"-l", "deploy", "-p", "2222", "--", "build-3", "docker", "system", "dial-stdio"}},
+		{"ssh://build-3/run/user/1000/docker.sock", []string{"--", "build-3", "docker", "--host", "unix:///run/user/1000/docker.sock", "system", "dial-stdio"}},
+	}
+
+	for _, cas := range cases {
+		dialer, err := newSSHDialer(cas.endpoint)
+		require.NoError(t, err)
+		require.Equal(t, "ssh", dialer.command)
+		require.Equal(t, cas.expected, dialer.args, "endpoint %s", cas.endpoint)
+	}
+
+	_, err := newSSHDialer("ssh://")
+	require.Error(t, err)
+	_, err = newSSHDialer("tcp://build-3:2375")
+	require.Error(t, err)
+}
+
+func TestCommandConn(t *testing.T) {
+	dialer := &sshDialer{command: "cat"}
+	conn, err := dialer.Dial("tcp", "docker.ssh")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /_ping"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 10)
+	_, err = io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	require.Equal(t, "GET /_ping", string(buffer))
+}
+
+func TestCommandConnError(t *testing.T) {
+	dialer := &sshDialer{command: "sh", args: []string{"-c", "echo 'Permission denied (publickey)' >&2; exit 255"}}
+	conn, err := dialer.Dial("tcp", "docker.ssh")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Read(make([]byte, 10))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Permission denied (publickey)")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/jawher/bateau/query"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeServices = `[
+	{
+		"ID": "svc1",
+		"CreatedAt": "2015-11-01T10:00:00Z",
+		"UpdatedAt": "2015-11-20T10:00:00Z",
+		"Spec": {
+			"Name": "web",
+			"Labels": {"team": "infra"},
+			"TaskTemplate": {"ContainerSpec": {"Image": "org/web:1.2"}},
+			"Mode": {"Replicated": {"Replicas": 3}}
+		}
+	},
+	{
+		"ID": "svc2",
+		"CreatedAt": "2015-11-01T10:00:00Z",
+		"Spec": {
+			"Name": "agent",
+			"TaskTemplate": {"ContainerSpec": {"Image": "org/agent:latest"}},
+			"Mode": {"Global": {}}
+		}
+	}
+]`
+	fakeNodes = `[
+	{
+		"ID": "node1",
+		"CreatedAt": "2015-01-01T10:00:00Z",
+		"Spec": {"Role": "manager", "Availability": "active", "Labels": {"zone": "eu"}},
+		"Description": {"Hostname": "build-1", "Engine": {"EngineVersion": "1.12.0"}},
+		"Status": {"State": "ready"}
+	},
+	{
+		"ID": "node2",
+		"CreatedAt": "2015-01-01T10:00:00Z",
+		"Spec": {"Role": "worker", "Availability": "drain"},
+		"Description": {"Hostname": "build-2", "Engine": {"EngineVersion": "1.11.2"}},
+		"Status": {"State": "down"}
+	}
+]`
+	fakeTasks = `[
+	{
+		"ID": "task1",
+		"CreatedAt": "2015-11-20T10:00:00Z",
+		"ServiceID": "svc1",
+		"NodeID": "node1",
+		"DesiredState": "running",
+		"Status": {"State": "running"}
+	},
+	{
+		"ID": "task2",
+		"CreatedAt": "2015-11-20T10:00:00Z",
+		"ServiceID": "svc1",
+		"NodeID": "node2",
+		"DesiredState": "running",
+		"Status": {"State": "rejected", "Err": "no suitable node"}
+	}
+]`
+)
+
+/*
+newFakeDocker starts an HTTP server answering the docker API list endpoints with the provided canned responses
+*/
+func newFakeDocker(t *testing.T, responses map[string]string) (*docker.Client, func()) {
+	server := newFakeDaemon(responses)
+	client, err := docker.NewClient(server.URL)
+	require.NoError(t, err)
+	return client, server.Close
+}
+
+// newFakeDaemon starts a server answering the docker API paths with the provided responses
+func newFakeDaemon(responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, found := responses[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, response)
+	}))
+}
+
+func matchingIDs(t *testing.T, tg target, client *docker.Client, queryStr string) []string {
+	matcher, err := query.Parse(queryStr, tg.fields, query.Types(tg.types))
+	require.NoError(t, err)
+
+	objects, err := tg.list(client)
+	require.NoError(t, err)
+
+	var res []string
+	for _, obj := range objects {
+		if matcher.Match(obj) {
+			id, _ := obj.Value("id")
+			res = append(res, id.(string))
+		}
+	}
+	return res
+}
+
+func TestSwarmQueries(t *testing.T) {
+	client, stop := newFakeDocker(t, map[string]string{
+		"/services": fakeServices,
+		"/nodes":    fakeNodes,
+		"/tasks":    fakeTasks,
+	})
+	defer stop()
+
+	cases := []struct {
+		target   target
+		query    string
+		expected []string
+	}{
+		{servicesTarget, "mode=global", []string{"svc2"}},
+		{servicesTarget, "replicas>2 & label.team=infra", []string{"svc1"}},
+		{servicesTarget, "!replicas>2", nil},
+		{servicesTarget, "replicas is null | replicas<=2", []string{"svc2"}},
+		{servicesTarget, "replicas is not null", []string{"svc1"}},
+		{servicesTarget, "replicas<10", []string{"svc1"}},
+		{servicesTarget, "replicas>=3 & replicas!=4", []string{"svc1"}},
+		{servicesTarget, "image~agent | name=web", []string{"svc1", "svc2"}},
+		{servicesTarget, "updated>1d", []string{"svc1"}},
+		{servicesTarget, "len(name)>3", []string{"svc2"}},
+		{servicesTarget, `split(basename(image), ":")[1]=latest`, []string{"svc2"}},
+		{servicesTarget, "upper(name)=WEB", []string{"svc1"}},
+
+		{nodesTarget, "role=manager & label.zone=eu", []string{"node1"}},
+		{nodesTarget, "availability!=active | status=down", []string{"node2"}},
+		{nodesTarget, "hostname~build & engine_version~1.11", []string{"node2"}},
+
+		{tasksTarget, "state!=running & desired_state=running", []string{"task2"}},
+		{tasksTarget, "service=web & node=build-1", []string{"task1"}},
+		{tasksTarget, "error~suitable", []string{"task2"}},
+		{tasksTarget, "len(error)>10", []string{"task2"}},
+	}
+
+	for _, cas := range cases {
+		require.Equal(t, cas.expected, matchingIDs(t, cas.target, client, cas.query),
+			"query '%s' on %s", cas.query, cas.target.name)
+	}
+}
+
+func TestSwarmOutput(t *testing.T) {
+	client, stop := newFakeDocker(t, map[string]string{
+		"/services": fakeServices,
+		"/nodes":    fakeNodes,
+		"/tasks":    fakeTasks,
+	})
+	defer stop()
+
+	tasks, err := listTasks(client)
+	require.NoError(t, err)
+
+	var buffer strings.Builder
+	out, err := newOutput(&buffer, "id,service,node,state,error", formatCSV, false, taskFields)
+	require.NoError(t, err)
+	for _, task := range tasks {
+		require.NoError(t, out.write(task))
+	}
+	require.NoError(t, out.flush())
+
+	require.Equal(t, "id,service,node,state,error\n"+
+		"task1,web,build-1,running,\n"+
+		"task2,web,build-2,rejected,no suitable node\n", buffer.String())
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/jawher/bateau/query"
+)
+
+var (
+	taskFields = map[string][]query.Operator{
+		"id":            {query.EQ, query.LIKE},
+		"state":         {query.EQ, query.LIKE},
+		"desired_state": {query.EQ, query.LIKE},
+		"service":       {query.EQ, query.LIKE},
+		"node":          {query.EQ, query.LIKE},
+		"error":         {query.EQ, query.LIKE},
+
+		"created": orderOperators,
+	}
+
+	taskTypes = map[string]query.Type{
+		"id":            query.TypeString,
+		"state":         query.TypeString,
+		"desired_state": query.TypeString,
+		"service":       query.TypeString,
+		"node":          query.TypeString,
+		"error":         query.TypeString,
+
+		"created": query.TypeDuration,
+	}
+)
+
+type DockerTask struct {
+	names *swarmNames
+	task  swarm.Task
+}
+
+var _ query.SliceQueryable = &DockerTask{}
+
+func listTasks(client *docker.Client) ([]queryable, error) {
+	tasks, err := client.ListTasks(docker.ListTasksOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := &swarmNames{client: client}
+	res := make([]queryable, len(tasks))
+	for i, task := range tasks {
+		res[i] = &DockerTask{names: names, task: task}
+	}
+	return res, nil
+}
+
+func (t *DockerTask) Is(field string, operator query.Operator, value string) bool {
+	v, found := t.Value(field)
+	return valueCompare(v, found, operator, value)
+}
+
+func (t *DockerTask) Elements(field string) []query.Queryable {
+	return elements(t, field)
+}
+
+// listedValue returns the value of the fields known from the task listing, the service and node names needing other listings
+func (t *DockerTask) listedValue(field string) (interface{}, bool) {
+	switch field {
+	case "service", "node":
+		return nil, false
+	default:
+		return t.Value(field)
+	}
+}
+
+/*
+Value returns the value of the provided field, or false if the task has no value for it.
+The service and node fields are resolved to the service name and the node hostname.
+*/
+func (t *DockerTask) Value(field string) (interface{}, bool) {
+	switch field {
+	case "id":
+		return t.task.ID, true
+	case "state":
+		return string(t.task.Status.State), true
+	case "desired_state":
+		return string(t.task.DesiredState), true
+	case "service":
+		name, found := t.names.service(t.task.ServiceID)
+		return name, found
+	case "node":
+		name, found := t.names.node(t.task.NodeID)
+		return name, found
+	case "error":
+		return t.task.Status.Err, len(t.task.Status.Err) != 0
+	case "created":
+		return t.task.CreatedAt, true
+	default:
+		panic(fmt.Sprintf("Invalid field %s", field))
+	}
+}
+
+/*
+swarmNames lazily resolves service and node ids to their names.
+Tasks only reference them by id, and listing them once is cheaper than inspecting them for every task.
+*/
+type swarmNames struct {
+	client   *docker.Client
+	services map[string]string
+	nodes    map[string]string
+}
+
+func (n *swarmNames) service(id string) (string, bool) {
+	if n.services == nil {
+		services, err := n.client.ListServices(docker.ListServicesOptions{})
+		if err != nil {
+			fail("Error while listing services: %v", err)
+		}
+		n.services = map[string]string{}
+		for _, service := range services {
+			n.services[service.ID] = service.Spec.Name
+		}
+	}
+	name, found := n.services[id]
+	return name, found
+}
+
+func (n *swarmNames) node(id string) (string, bool) {
+	if n.nodes == nil {
+		nodes, err := n.client.ListNodes(docker.ListNodesOptions{})
+		if err != nil {
+			fail("Error while listing nodes: %v", err)
+		}
+		n.nodes = map[string]string{}
+		for _, node := range nodes {
+			n.nodes[node.ID] = node.Description.Hostname
+		}
+	}
+	name, found := n.nodes[id]
+	return name, found
+}
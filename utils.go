@@ -12,19 +12,85 @@ import (
 	"github.com/jawher/bateau/query"
 )
 
+// byteSize is the type of size valued fields, to tell them apart from plain integers
+type byteSize int64
+
+/*
+valueCompare matches a field value, as returned by the Value methods, against the provided operator and pattern.
+Missing values never match.
+*/
+func valueCompare(value interface{}, found bool, op query.Operator, pattern string) bool {
+	if op == query.IS {
+		if b, ok := value.(bool); ok {
+			return found && b
+		}
+		return found
+	}
+	if !found {
+		return false
+	}
+	switch v := value.(type) {
+	case string:
+		return strCompare(v, op, pattern)
+	case []string:
+		return sliceCompare(v, op, pattern)
+	case int:
+		return intCompare(v, op, pattern)
+	case byteSize:
+		return sizeCompare(int64(v), op, pattern)
+	case time.Time:
+		return durationCompare(v, op, pattern)
+	default:
+		panic(fmt.Sprintf("Unsupported value type %T", value))
+	}
+}
+
+/*
+elements splits the value of a slice field in one queryable per element, for the any, all and none quantifiers.
+Single-valued fields are considered as slices of one element, and missing ones as empty slices.
+*/
+func elements(v valuer, field string) []query.Queryable {
+	value, found := v.Value(field)
+	if !found {
+		return nil
+	}
+	values, ok := value.([]string)
+	if !ok {
+		return []query.Queryable{element{field: field, value: value}}
+	}
+	res := make([]query.Queryable, len(values))
+	for i, value := range values {
+		res[i] = element{field: field, value: value}
+	}
+	return res
+}
+
+// element is the queryable of a single element of a field
+type element struct {
+	field string
+	value interface{}
+}
+
+func (e element) Is(field string, operator query.Operator, value string) bool {
+	if field != e.field {
+		panic(fmt.Sprintf("Invalid field %s, was expecting %s", field, e.field))
+	}
+	return valueCompare(e.value, true, operator, value)
+}
+
+func (e element) Value(field string) (interface{}, bool) {
+	if field != e.field {
+		panic(fmt.Sprintf("Invalid field %s, was expecting %s", field, e.field))
+	}
+	return e.value, true
+}
+
 func intCompare(value int, op query.Operator, pattern string) bool {
 	ipattern, err := strconv.Atoi(pattern)
 	if err != nil {
 		panic(fmt.Sprintf("'%s' is not a numeric", op))
 	}
-	switch op {
-	case query.EQ:
-		return value == ipattern
-	case query.GT:
-		return value > ipattern
-	default:
-		panic(fmt.Sprintf("Unsupported operator %s", op))
-	}
+	return orderCompare(int64(value), op, int64(ipattern))
 }
 
 func strCompare(value string, op query.Operator, pattern string) bool {
@@ -41,31 +107,39 @@ func strCompare(value string, op query.Operator, pattern string) bool {
 var durationBaseTime = func() time.Time { return time.Now() }
 
 func durationCompare(value time.Time, op query.Operator, pattern string) bool {
-	duration, err := parseDuration(pattern)
+	duration, err := query.ParseDuration(pattern)
 	if err != nil {
 		panic(err)
 	}
 	v := durationBaseTime().Sub(value)
-	switch op {
-	case query.EQ:
-		return v == duration
-	case query.GT:
-		return v.Nanoseconds() > duration.Nanoseconds()
-	default:
-		panic(fmt.Sprintf("Unsupported operator %s", op))
-	}
+	return orderCompare(v.Nanoseconds(), op, duration.Nanoseconds())
 }
 
 func sizeCompare(value int64, op query.Operator, pattern string) bool {
-	against, err := parseSize(pattern)
+	against, err := query.ParseSize(pattern)
 	if err != nil {
 		panic(err)
 	}
+	return orderCompare(value, op, against)
+}
+
+// orderOperators are the operators supported by the numeric, size and duration fields
+var orderOperators = []query.Operator{query.EQ, query.NE, query.GT, query.GE, query.LT, query.LE}
+
+func orderCompare(value int64, op query.Operator, against int64) bool {
 	switch op {
 	case query.EQ:
 		return value == against
+	case query.NE:
+		return value != against
 	case query.GT:
 		return value > against
+	case query.GE:
+		return value >= against
+	case query.LT:
+		return value < against
+	case query.LE:
+		return value <= against
 	default:
 		panic(fmt.Sprintf("Unsupported operator %s", op))
 	}
@@ -92,20 +166,3 @@ func sliceCompare(values []string, op query.Operator, pattern string) bool {
 func like(value, pattern string) bool {
 	return strings.Contains(strings.ToLower(value), strings.ToLower(pattern))
 }
-
-type parser struct {
-	input string
-	pos   int
-}
-
-func (p *parser) eatWs() {
-	for ; p.pos < len(p.input); p.pos++ {
-		if p.input[p.pos] != ' ' {
-			return
-		}
-	}
-}
-
-func (p *parser) eof() bool {
-	return p.pos >= len(p.input)
-}
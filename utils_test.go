@@ -15,6 +15,15 @@ func TestIntCompare(t *testing.T) {
 
 	require.True(t, intCompare(2, query.GT, "1"))
 	require.False(t, intCompare(1, query.GT, "1"))
+
+	require.True(t, intCompare(1, query.NE, "2"))
+	require.False(t, intCompare(1, query.NE, "1"))
+	require.True(t, intCompare(1, query.GE, "1"))
+	require.False(t, intCompare(0, query.GE, "1"))
+	require.True(t, intCompare(0, query.LT, "1"))
+	require.False(t, intCompare(1, query.LT, "1"))
+	require.True(t, intCompare(1, query.LE, "1"))
+	require.False(t, intCompare(2, query.LE, "1"))
 }
 
 func TestStrCompare(t *testing.T) {
@@ -43,6 +52,10 @@ func TestDurationCompare(t *testing.T) {
 
 	require.False(t, durationCompare(base.Add(-1*time.Hour), query.EQ, "1h 1m"))
 	require.False(t, durationCompare(base.Add(-1*time.Hour), query.GT, "1h 1s"))
+
+	require.True(t, durationCompare(base.Add(-1*time.Hour), query.LT, "1h 1s"))
+	require.True(t, durationCompare(base.Add(-1*time.Hour), query.LE, "1h"))
+	require.False(t, durationCompare(base.Add(-1*time.Hour), query.GE, "1h 1s"))
 }
 
 func TestSizeCompare(t *testing.T) {
@@ -65,3 +78,43 @@ func TestSliceCompare(t *testing.T) {
 	require.False(t, sliceCompare([]string{"niet", "test"}, query.LIKE, "42"))
 
 }
+
+func TestValueCompare(t *testing.T) {
+	require.True(t, valueCompare(true, true, query.IS, ""))
+	require.False(t, valueCompare(false, true, query.IS, ""))
+	require.True(t, valueCompare("", true, query.IS, ""))
+	require.False(t, valueCompare("", false, query.IS, ""))
+
+	require.True(t, valueCompare("test", true, query.EQ, "test"))
+	require.True(t, valueCompare([]string{"niet", "test"}, true, query.LIKE, "Est"))
+	require.True(t, valueCompare(2, true, query.GT, "1"))
+	require.True(t, valueCompare(byteSize(42*1024), true, query.EQ, "42KB"))
+	require.True(t, valueCompare(time.Now().Add(-1*time.Hour), true, query.GT, "1m"))
+
+	require.False(t, valueCompare(0, false, query.EQ, "0"))
+	require.False(t, valueCompare(time.Time{}, false, query.GT, "1m"))
+	require.False(t, valueCompare(0, false, query.LT, "1"))
+	require.False(t, valueCompare(0, false, query.NE, "1"))
+}
+
+func TestElements(t *testing.T) {
+	obj := mapValuer{
+		"cmd":  []string{"sh", "-c"},
+		"name": "web",
+	}
+
+	cmd := elements(obj, "cmd")
+	require.Len(t, cmd, 2)
+	require.True(t, cmd[0].Is("cmd", query.EQ, "sh"))
+	require.False(t, cmd[1].Is("cmd", query.EQ, "sh"))
+	require.True(t, cmd[1].Is("cmd", query.LIKE, "C"))
+	value, found := cmd[1].(query.ValueQueryable).Value("cmd")
+	require.True(t, found)
+	require.Equal(t, "-c", value)
+
+	name := elements(obj, "name")
+	require.Len(t, name, 1)
+	require.True(t, name[0].Is("name", query.EQ, "web"))
+
+	require.Empty(t, elements(obj, "label.missing"))
+}